@@ -0,0 +1,60 @@
+package azurestorage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+)
+
+// ================================================================================================================================================
+// Azure Storage - BLOB Append Functions
+// ================================================================================================================================================
+
+// AppendToBlob appends data to the append blob blobName, creating it first if it doesn't exist, and returns the number
+// of blocks committed to the blob afterwards. Data larger than the 4 MiB limit of a single block is appended as
+// several blocks; a concurrent writer may interleave its own blocks between them.
+func AppendToBlob(ctx context.Context, containerURL azblob.ContainerURL, blobName *string, data io.ReadSeeker) (azblob.AppendBlobURL, int32, error) {
+	blobURL := containerURL.NewAppendBlobURL(*blobName) // Blob names can be mixed case
+
+	// Create the blob unless it exists; losing the race to another writer creating it is fine
+	_, err := blobURL.Create(ctx, azblob.BlobHTTPHeaders{}, azblob.Metadata{}, azblob.BlobAccessConditions{
+		ModifiedAccessConditions: azblob.ModifiedAccessConditions{IfNoneMatch: azblob.ETagAny},
+	}, nil, azblob.ClientProvidedKeyOptions{})
+	var stgErr azblob.StorageError
+	if err != nil && !isConditionNotMet(err) && !(errors.As(err, &stgErr) && stgErr.ServiceCode() == azblob.ServiceCodeBlobAlreadyExists) {
+		return azblob.AppendBlobURL{}, 0, err
+	}
+
+	// Append the data one block at a time
+	committed := int32(-1)
+	buf := make([]byte, azblob.AppendBlobMaxAppendBlockBytes)
+	for {
+		n, err := io.ReadFull(data, buf)
+		if err == io.EOF {
+			break
+		}
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return azblob.AppendBlobURL{}, 0, err
+		}
+
+		resp, err := blobURL.AppendBlock(ctx, bytes.NewReader(buf[:n]), azblob.AppendBlobAccessConditions{}, nil, azblob.ClientProvidedKeyOptions{})
+		if err != nil {
+			return azblob.AppendBlobURL{}, 0, err
+		}
+		committed = resp.BlobCommittedBlockCount()
+	}
+
+	// Nothing was appended, so the count has to be read from the blob
+	if committed < 0 {
+		props, err := blobURL.GetProperties(ctx, azblob.BlobAccessConditions{}, azblob.ClientProvidedKeyOptions{})
+		if err != nil {
+			return azblob.AppendBlobURL{}, 0, err
+		}
+		committed = props.BlobCommittedBlockCount()
+	}
+
+	return blobURL, committed, nil
+}
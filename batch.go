@@ -0,0 +1,165 @@
+package azurestorage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+)
+
+// ================================================================================================================================================
+// Azure Storage - BLOB Batch Functions
+// ================================================================================================================================================
+
+// ErrTierPending is reported by VerifyBlobTiers for a blob that is still being rehydrated to its target tier.
+var ErrTierPending = errors.New("azurestorage: access tier change is still pending")
+
+// SetBlobTiersBatch sets the access tier of every blob in items. The azblob SDK doesn't expose the service's batch
+// endpoint, so the tiers are set with concurrent SetTier calls instead. A failure for one blob doesn't stop the
+// others: the first result holds one error per failed blob, the second reports a request that couldn't be started.
+func SetBlobTiersBatch(ctx context.Context, containerURL azblob.ContainerURL, items map[string]azblob.AccessTierType) ([]error, error) {
+	names, err := sortedTierNames(items)
+	if err != nil {
+		return nil, err
+	}
+
+	results := setTiers(ctx, containerURL, names, func(name string) azblob.AccessTierType { return items[name] })
+	for i, err := range results {
+		if err != nil {
+			results[i] = fmt.Errorf("%s: %w", names[i], err)
+		}
+	}
+
+	return compactErrors(results), nil
+}
+
+// SetBlobsToTier moves every blob in blobNames to tier, such as archiving hundreds of blobs at once. Like
+// SetBlobTiersBatch, it sets the tiers with concurrent SetTier calls, so the service's limit of 256 operations per
+// batch doesn't apply. A failure for one blob doesn't stop the others: failed maps the name of every blob whose tier
+// couldn't be set to its error, and err reports a call that couldn't be started.
+func SetBlobsToTier(ctx context.Context, containerURL azblob.ContainerURL, blobNames []string, tier azblob.AccessTierType) (failed map[string]error, err error) {
+	if tier == azblob.AccessTierNone {
+		return nil, errors.New("azurestorage: no access tier given")
+	}
+	for _, name := range blobNames {
+		if name == "" {
+			return nil, errors.New("azurestorage: empty blob name in tier batch")
+		}
+	}
+
+	results := setTiers(ctx, containerURL, blobNames, func(string) azblob.AccessTierType { return tier })
+
+	return failuresByName(blobNames, results), nil
+}
+
+// setTiers sets the tier of each of names concurrently and returns the error of each, nil for the blobs that succeeded.
+func setTiers(ctx context.Context, containerURL azblob.ContainerURL, names []string, tierOf func(name string) azblob.AccessTierType) []error {
+	results := make([]error, len(names))
+	runConcurrently(len(names), defaultConcurrency, func(i int) {
+		blobURL := containerURL.NewBlobURL(names[i])
+
+		_, results[i] = blobURL.SetTier(ctx, tierOf(names[i]), azblob.LeaseAccessConditions{})
+	})
+
+	return results
+}
+
+// DeleteBlobsBatch deletes every blob in blobNames, along with its snapshots. The azblob SDK doesn't expose the
+// service's batch endpoint, so the blobs are deleted with concurrent Delete calls instead, which still clears a large
+// container in a fraction of the time of deleting one blob after another. A failure for one blob doesn't stop the
+// others: failed maps the name of every blob that couldn't be deleted to its error, and err reports a call that
+// couldn't be started.
+func DeleteBlobsBatch(ctx context.Context, containerURL azblob.ContainerURL, blobNames []string) (failed map[string]error, err error) {
+	for _, name := range blobNames {
+		if name == "" {
+			return nil, errors.New("azurestorage: empty blob name in delete batch")
+		}
+	}
+
+	results := make([]error, len(blobNames))
+	runConcurrently(len(blobNames), defaultConcurrency, func(i int) {
+		blobURL := containerURL.NewBlobURL(blobNames[i])
+
+		_, results[i] = blobURL.Delete(ctx, azblob.DeleteSnapshotsOptionInclude, azblob.BlobAccessConditions{})
+	})
+
+	return failuresByName(blobNames, results), nil
+}
+
+// VerifyBlobTiers re-reads the properties of every blob in items and reports the blobs whose tier doesn't match yet.
+// Moving a blob out of Archive is asynchronous; a blob that is still rehydrating to its target is reported with
+// ErrTierPending so callers can check again later.
+func VerifyBlobTiers(ctx context.Context, containerURL azblob.ContainerURL, items map[string]azblob.AccessTierType) ([]error, error) {
+	names, err := sortedTierNames(items)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]error, len(names))
+	runConcurrently(len(names), defaultConcurrency, func(i int) {
+		blobURL := containerURL.NewBlobURL(names[i])
+		tier := items[names[i]]
+
+		props, err := blobURL.GetProperties(ctx, azblob.BlobAccessConditions{}, azblob.ClientProvidedKeyOptions{})
+		switch {
+		case err != nil:
+			results[i] = fmt.Errorf("%s: %w", names[i], err)
+		case azblob.AccessTierType(props.AccessTier()) == tier:
+			// The tier took effect
+		case isRehydratingTo(props.ArchiveStatus(), tier):
+			results[i] = fmt.Errorf("%s: %w", names[i], ErrTierPending)
+		default:
+			results[i] = fmt.Errorf("%s: access tier is %s, expected %s", names[i], props.AccessTier(), tier)
+		}
+	})
+
+	return compactErrors(results), nil
+}
+
+func isRehydratingTo(archiveStatus string, tier azblob.AccessTierType) bool {
+	switch azblob.ArchiveStatusType(archiveStatus) {
+	case azblob.ArchiveStatusRehydratePendingToHot:
+		return tier == azblob.AccessTierHot
+	case azblob.ArchiveStatusRehydratePendingToCool:
+		return tier == azblob.AccessTierCool
+	}
+	return false
+}
+
+func sortedTierNames(items map[string]azblob.AccessTierType) ([]string, error) {
+	names := make([]string, 0, len(items))
+	for name, tier := range items {
+		if tier == azblob.AccessTierNone {
+			return nil, fmt.Errorf("azurestorage: no access tier given for blob %q", name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+func compactErrors(errs []error) []error {
+	var failed []error
+	for _, err := range errs {
+		if err != nil {
+			failed = append(failed, err)
+		}
+	}
+
+	return failed
+}
+
+// failuresByName maps each of names to its error in results, leaving out the names that succeeded.
+func failuresByName(names []string, results []error) map[string]error {
+	failed := map[string]error{}
+	for i, err := range results {
+		if err != nil {
+			failed[names[i]] = err
+		}
+	}
+
+	return failed
+}
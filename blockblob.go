@@ -0,0 +1,87 @@
+package azurestorage
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+)
+
+// ================================================================================================================================================
+// Azure Storage - BLOB Block Functions
+// ================================================================================================================================================
+
+const maxBlockIDLength = 64 // Bytes of a block ID before it is base64-encoded
+
+// ErrInvalidBlockID is returned for a block ID that is empty or longer than 64 bytes, or for a block list whose IDs
+// differ in length, which the service rejects for a single blob.
+var ErrInvalidBlockID = errors.New("azurestorage: invalid block ID")
+
+// StageBlock uploads data, of at most 4000 MiB, as the uncommitted block blockID of the block blob, for uploads that
+// pick their own chunking. The block is invisible until CommitBlockList includes it and is discarded by the service
+// after a week without a commit. blockID is a plain string, base64-encoded here; every block ID of one blob must have
+// the same length, so use fixed-width IDs such as fmt.Sprintf("%08d", index). Staging a block again replaces it, so a
+// restarted upload can simply resend the blocks GetUncommittedBlocks doesn't report.
+func StageBlock(ctx context.Context, blobURL azblob.BlockBlobURL, blockID string, data io.ReadSeeker) error {
+	if err := checkBlockID(blockID); err != nil {
+		return err
+	}
+
+	_, err := blobURL.StageBlock(ctx, encodeBlockID(blockID), data, azblob.LeaseAccessConditions{}, nil, azblob.ClientProvidedKeyOptions{})
+	return err
+}
+
+// CommitBlockList makes the block blob consist of the staged blocks blockIDs, in that order, replacing any content it
+// had; staged blocks left out are discarded. The IDs are the plain strings given to StageBlock. Committing resets the
+// blob's HTTP headers and metadata, so set the content type afterwards with SetBlobHTTPHeaders.
+func CommitBlockList(ctx context.Context, blobURL azblob.BlockBlobURL, blockIDs []string) error {
+	encoded := make([]string, len(blockIDs))
+	for i, blockID := range blockIDs {
+		if err := checkBlockID(blockID); err != nil {
+			return err
+		}
+		if len(blockID) != len(blockIDs[0]) {
+			return fmt.Errorf("%w: %q and %q differ in length", ErrInvalidBlockID, blockIDs[0], blockID)
+		}
+		encoded[i] = encodeBlockID(blockID)
+	}
+
+	_, err := blobURL.CommitBlockList(ctx, encoded, azblob.BlobHTTPHeaders{}, azblob.Metadata{}, azblob.BlobAccessConditions{}, azblob.DefaultAccessTier, nil, azblob.ClientProvidedKeyOptions{})
+	return err
+}
+
+// GetUncommittedBlocks returns the IDs of the blocks staged for the block blob but not committed yet, decoded back to
+// the plain strings given to StageBlock, so an interrupted upload can tell which blocks it still has to send. A blob
+// that doesn't exist yet but has staged blocks is reported like any other.
+func GetUncommittedBlocks(ctx context.Context, blobURL azblob.BlockBlobURL) ([]string, error) {
+	blockList, err := blobURL.GetBlockList(ctx, azblob.BlockListUncommitted, azblob.LeaseAccessConditions{})
+	if err != nil {
+		return nil, err
+	}
+
+	blockIDs := make([]string, 0, len(blockList.UncommittedBlocks))
+	for _, block := range blockList.UncommittedBlocks {
+		blockID, err := base64.StdEncoding.DecodeString(block.Name)
+		if err != nil {
+			return nil, fmt.Errorf("%w: staged block %q isn't base64-encoded", ErrInvalidBlockID, block.Name)
+		}
+		blockIDs = append(blockIDs, string(blockID))
+	}
+
+	return blockIDs, nil
+}
+
+func checkBlockID(blockID string) error {
+	if blockID == "" || len(blockID) > maxBlockIDLength {
+		return fmt.Errorf("%w: %q must be 1 to %d bytes long", ErrInvalidBlockID, blockID, maxBlockIDLength)
+	}
+
+	return nil
+}
+
+func encodeBlockID(blockID string) string {
+	return base64.StdEncoding.EncodeToString([]byte(blockID))
+}
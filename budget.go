@@ -0,0 +1,61 @@
+package azurestorage
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// ================================================================================================================================================
+// Azure Storage - Deadline Budget
+// ================================================================================================================================================
+
+// BudgetStep is one sub-operation of a composite operation run by RunWithBudget.
+type BudgetStep struct {
+	Weight float64                         // Share of the remaining time relative to the steps that follow; <= 0 counts as 1
+	Run    func(ctx context.Context) error // The sub-operation, which must honour ctx
+}
+
+// RunWithBudget runs steps in order under the deadline of ctx. Each step gets a sub-deadline proportional to its weight
+// among the steps still to run, so time left over by a fast step is shared by the following ones while a slow step
+// can't starve them. The composite stops at the first failing step, or as soon as the overall deadline has passed.
+// Without a deadline on ctx every step simply runs with ctx.
+func RunWithBudget(ctx context.Context, steps ...BudgetStep) error {
+	for i, step := range steps {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("azurestorage: budget exhausted before step %d of %d: %w", i+1, len(steps), err)
+		}
+
+		stepCtx, cancel := budgetContext(ctx, steps[i:])
+		err := step.Run(stepCtx)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("azurestorage: step %d of %d: %w", i+1, len(steps), err)
+		}
+	}
+
+	return nil
+}
+
+// budgetContext derives the context of remaining[0], giving it its weighted share of the time left on ctx.
+func budgetContext(ctx context.Context, remaining []BudgetStep) (context.Context, context.CancelFunc) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return context.WithCancel(ctx)
+	}
+
+	total := 0.0
+	for _, step := range remaining {
+		total += stepWeight(step)
+	}
+
+	share := time.Duration(float64(time.Until(deadline)) * stepWeight(remaining[0]) / total)
+	return context.WithTimeout(ctx, share)
+}
+
+func stepWeight(step BudgetStep) float64 {
+	if step.Weight <= 0 {
+		return 1
+	}
+	return step.Weight
+}
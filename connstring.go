@@ -0,0 +1,103 @@
+package azurestorage
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+)
+
+// ================================================================================================================================================
+// Azure Storage - Connection Strings
+// ================================================================================================================================================
+
+// The well-known account of the storage emulator (Azurite), used by UseDevelopmentStorage=true.
+const (
+	devStoreAccountName = "devstoreaccount1"
+	devStoreAccountKey  = "Eby8vdM02xNOcqFlqUwJPLlmEtlCDXJ1OUzFT50uSRZ6IFsuFq2UVErCz4I6tq/K1SZFPTOtr/KBHBeksoGMGw=="
+	devStoreBlobPort    = "10000"
+)
+
+// GetBlobServiceFromConnectionString returns the blob service described by a storage connection string, as shown in
+// the Azure portal: "DefaultEndpointsProtocol=https;AccountName=...;AccountKey=...;EndpointSuffix=core.windows.net".
+// An explicit BlobEndpoint overrides the endpoint built from the other settings, a SharedAccessSignature is used in
+// place of an AccountKey, and "UseDevelopmentStorage=true" connects to the local storage emulator.
+func GetBlobServiceFromConnectionString(connStr string, options ...Option) (azblob.ServiceURL, error) {
+	settings, err := parseConnectionString(connStr)
+	if err != nil {
+		return azblob.ServiceURL{}, err
+	}
+
+	if strings.EqualFold(settings["UseDevelopmentStorage"], "true") {
+		proxy := settings["DevelopmentStorageProxyUri"]
+		if proxy == "" {
+			proxy = "http://127.0.0.1"
+		}
+		settings = map[string]string{
+			"AccountName":  devStoreAccountName,
+			"AccountKey":   devStoreAccountKey,
+			"BlobEndpoint": fmt.Sprintf("%s:%s/%s", strings.TrimSuffix(proxy, "/"), devStoreBlobPort, devStoreAccountName),
+		}
+	}
+
+	// Work out the endpoint, unless the connection string names it
+	endpoint := settings["BlobEndpoint"]
+	if endpoint == "" {
+		if settings["AccountName"] == "" {
+			return azblob.ServiceURL{}, errors.New("azurestorage: connection string has neither AccountName nor BlobEndpoint")
+		}
+		protocol := settings["DefaultEndpointsProtocol"]
+		if protocol == "" {
+			protocol = "https"
+		}
+		suffix := settings["EndpointSuffix"]
+		if suffix == "" {
+			suffix = "core.windows.net"
+		}
+		endpoint = fmt.Sprintf("%s://%s.blob.%s", protocol, settings["AccountName"], suffix)
+	}
+
+	if sas := settings["SharedAccessSignature"]; sas != "" {
+		return GetBlobServiceWithSAS(strings.TrimSuffix(endpoint, "/")+"/?"+strings.TrimPrefix(sas, "?"), options...)
+	}
+
+	if settings["AccountName"] == "" || settings["AccountKey"] == "" {
+		return azblob.ServiceURL{}, errors.New("azurestorage: connection string has neither an AccountName and AccountKey nor a SharedAccessSignature")
+	}
+	credential, err := azblob.NewSharedKeyCredential(settings["AccountName"], settings["AccountKey"])
+	if err != nil {
+		return azblob.ServiceURL{}, err
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return azblob.ServiceURL{}, err
+	}
+	p, err := newBlobPipeline(credential, options)
+	if err != nil {
+		return azblob.ServiceURL{}, err
+	}
+
+	return azblob.NewServiceURL(*u, p), nil
+}
+
+// parseConnectionString splits a connection string into its settings. Values may contain "=", as account keys and SAS
+// tokens do, so only the first one of each segment separates the name.
+func parseConnectionString(connStr string) (map[string]string, error) {
+	settings := map[string]string{}
+	for _, segment := range strings.Split(connStr, ";") {
+		segment = strings.TrimSpace(segment)
+		if segment == "" {
+			continue
+		}
+
+		eq := strings.Index(segment, "=")
+		if eq <= 0 {
+			return nil, fmt.Errorf("azurestorage: malformed connection string segment %q", segment)
+		}
+		settings[segment[:eq]] = segment[eq+1:]
+	}
+
+	return settings, nil
+}
@@ -0,0 +1,50 @@
+package azurestorage
+
+import (
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+// ================================================================================================================================================
+// Azure Storage - Content Type Detection
+// ================================================================================================================================================
+
+// sniffLength is the number of leading bytes http.DetectContentType considers.
+const sniffLength = 512
+
+// fallbackContentTypes covers common extensions that are missing from Go's built-in table on systems without a
+// mime.types file; sniffing them gives a less specific type (a CSV file sniffs as text/plain).
+var fallbackContentTypes = map[string]string{
+	".csv":  "text/csv; charset=utf-8",
+	".tsv":  "text/tab-separated-values; charset=utf-8",
+	".txt":  "text/plain; charset=utf-8",
+	".md":   "text/markdown; charset=utf-8",
+	".yaml": "application/yaml",
+	".yml":  "application/yaml",
+	".gz":   "application/gzip",
+	".zip":  "application/zip",
+	".tar":  "application/x-tar",
+	".mp4":  "video/mp4",
+	".mp3":  "audio/mpeg",
+}
+
+// DetectContentType resolves the content type of the file at path. The extension is preferred; only when it is
+// unknown are the first bytes of the content (peek, up to 512 bytes are used) sniffed.
+func DetectContentType(path string, peek []byte) string {
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext != "" {
+		if contentType := mime.TypeByExtension(ext); contentType != "" {
+			return contentType
+		}
+		if contentType, ok := fallbackContentTypes[ext]; ok {
+			return contentType
+		}
+	}
+
+	if len(peek) > sniffLength {
+		peek = peek[:sniffLength]
+	}
+	return http.DetectContentType(peek)
+}
@@ -0,0 +1,334 @@
+package azurestorage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-blob-go/azblob"
+)
+
+// ================================================================================================================================================
+// Azure Storage - BLOB Copy Functions
+// ================================================================================================================================================
+
+// CopyBackoff controls how often a pending server-side copy is checked for completion. The first check happens
+// after Initial; every following wait is Multiplier times longer, up to Max.
+type CopyBackoff struct {
+	Initial    time.Duration
+	Max        time.Duration
+	Multiplier float64
+}
+
+// DefaultCopyBackoff notices a small same-account copy within a fraction of a second, and settles on one check every
+// 30 seconds for copies that take hours.
+var DefaultCopyBackoff = CopyBackoff{Initial: 100 * time.Millisecond, Max: 30 * time.Second, Multiplier: 2}
+
+// next returns the wait that follows wait.
+func (b CopyBackoff) next(wait time.Duration) time.Duration {
+	if b.Multiplier > 1 {
+		wait = time.Duration(float64(wait) * b.Multiplier)
+	}
+	if b.Max > 0 && wait > b.Max {
+		wait = b.Max
+	}
+	return wait
+}
+
+// Metadata keys stamped by WithOriginalTimestamps. Metadata names must be valid C# identifiers, hence the underscores.
+const (
+	OriginalLastModifiedKey = "original_last_modified"
+	OriginalCreatedKey      = "original_created"
+)
+
+// CopyOption customises a server-side blob copy.
+type CopyOption func(*copyOptions)
+
+type copyOptions struct {
+	originalTimestamps bool        // Stamp the source's timestamps into the destination's metadata
+	backoff            CopyBackoff // Polling schedule while the copy is pending
+}
+
+// WithOriginalTimestamps records the source's last-modified and creation times (RFC 3339, UTC) in the destination's
+// metadata, as Azure gives the copy timestamps of its own. A source that already carries the keys, because it is
+// itself a copy, keeps its values so the oldest dates survive repeated copies.
+func WithOriginalTimestamps() CopyOption {
+	return func(o *copyOptions) {
+		o.originalTimestamps = true
+	}
+}
+
+// WithCopyBackoff polls a pending copy on the given schedule instead of DefaultCopyBackoff. An Initial that isn't
+// positive or a Multiplier below 1, which would poll the service in a tight loop, is taken from DefaultCopyBackoff.
+func WithCopyBackoff(backoff CopyBackoff) CopyOption {
+	return func(o *copyOptions) {
+		if backoff.Initial <= 0 {
+			backoff.Initial = DefaultCopyBackoff.Initial
+		}
+		if backoff.Multiplier < 1 {
+			backoff.Multiplier = DefaultCopyBackoff.Multiplier
+		}
+		o.backoff = backoff
+	}
+}
+
+func newCopyOptions(options []CopyOption) copyOptions {
+	o := copyOptions{backoff: DefaultCopyBackoff}
+	for _, option := range options {
+		option(&o)
+	}
+
+	return o
+}
+
+// CopyBlob copies srcBlobURL to dstBlobName in dstContainerURL server-side, keeping the source's access tier, and
+// returns once the copy has completed. A failed or aborted copy is returned as an error carrying the service's
+// CopyStatusDescription.
+func CopyBlob(ctx context.Context, srcBlobURL azblob.BlobURL, dstContainerURL azblob.ContainerURL, dstBlobName *string, options ...CopyOption) (azblob.BlobURL, error) {
+	return CopyBlobWithTier(ctx, srcBlobURL, dstContainerURL, dstBlobName, azblob.AccessTierNone, options...)
+}
+
+// StartCopyBlob starts copying srcBlobURL to dstBlobName in dstContainerURL and returns without waiting, with the
+// destination and the copy ID. Poll the destination's GetProperties until its CopyID matches and its CopyStatus is no
+// longer pending, or abort the copy with AbortCopyFromURL.
+func StartCopyBlob(ctx context.Context, srcBlobURL azblob.BlobURL, dstContainerURL azblob.ContainerURL, dstBlobName *string) (azblob.BlobURL, string, error) {
+	dstBlobURL := dstContainerURL.NewBlobURL(*dstBlobName) // Blob names can be mixed case
+
+	copyResp, err := dstBlobURL.StartCopyFromURL(ctx, srcBlobURL.URL(), azblob.Metadata{}, azblob.ModifiedAccessConditions{}, azblob.BlobAccessConditions{}, azblob.DefaultAccessTier, nil)
+	if err != nil {
+		return azblob.BlobURL{}, "", err
+	}
+
+	return dstBlobURL, copyResp.CopyID(), nil
+}
+
+// CopyBlobWithTier copies srcBlobURL to dstBlobName in dstContainerURL on the service side, landing the copy straight
+// in tier, and returns the copy's URL once the copy completes. With AccessTierNone the copy takes the source's tier
+// instead of the account default. The copy keeps the source's metadata; pass WithOriginalTimestamps to record the
+// source's timestamps in it and WithCopyBackoff to tune how the copy is polled. A source in another account must carry
+// a SAS with read access.
+func CopyBlobWithTier(ctx context.Context, srcBlobURL azblob.BlobURL, dstContainerURL azblob.ContainerURL, dstBlobName *string, tier azblob.AccessTierType, options ...CopyOption) (azblob.BlobURL, error) {
+	o := newCopyOptions(options)
+
+	metadata := azblob.Metadata{} // Empty metadata makes the copy keep the source's metadata
+	if tier == azblob.AccessTierNone || o.originalTimestamps {
+		props, err := srcBlobURL.GetProperties(ctx, azblob.BlobAccessConditions{}, azblob.ClientProvidedKeyOptions{})
+		if err != nil {
+			return azblob.BlobURL{}, err
+		}
+		// Without an explicit tier the copy lands in the source's tier instead of the account default.
+		if tier == azblob.AccessTierNone {
+			tier = azblob.AccessTierType(props.AccessTier())
+		}
+		if o.originalTimestamps {
+			metadata = originalTimestampMetadata(props)
+		}
+	}
+
+	// Create a URL that references the to-be-created copy in the destination container.
+	dstBlobURL := dstContainerURL.NewBlobURL(*dstBlobName) // Blob names can be mixed case
+
+	// Start the server-side copy, setting the tier as part of the copy itself, and wait for it to finish
+	copyResp, err := dstBlobURL.StartCopyFromURL(ctx, srcBlobURL.URL(), metadata, azblob.ModifiedAccessConditions{}, azblob.BlobAccessConditions{}, tier, nil)
+	if err != nil {
+		return azblob.BlobURL{}, err
+	}
+
+	err = waitForBlobCopy(ctx, dstBlobURL, copyResp.CopyID(), copyResp.CopyStatus(), o.backoff)
+	if err != nil {
+		return azblob.BlobURL{}, err
+	}
+
+	return dstBlobURL, nil
+}
+
+// CopyContainer copies every blob of srcContainerURL to the same name in dstContainerURL on the service side, for
+// example to clone an environment, creating the destination container if it doesn't exist. At most concurrency copies
+// run at the same time, each waited for until it completes; every copy keeps its source's metadata, content type and
+// access tier, and overwrites a destination blob of the same name. Failed blobs don't stop the others: copied counts
+// the blobs that were copied, and the error reports how many failed along with the first failure.
+func CopyContainer(ctx context.Context, srcContainerURL, dstContainerURL azblob.ContainerURL, concurrency int) (copied int, err error) {
+	_, err = dstContainerURL.Create(ctx, azblob.Metadata{}, azblob.PublicAccessNone)
+	var stgErr azblob.StorageError
+	if err != nil && !(errors.As(err, &stgErr) && stgErr.ServiceCode() == azblob.ServiceCodeContainerAlreadyExists) {
+		return 0, err
+	}
+
+	// List the whole source first, so the copies can't change what is being listed. The metadata carries the logical
+	// names of a service created with WithPartitionPrefix.
+	var names []string
+	for marker := (azblob.Marker{}); marker.NotDone(); { // The parens around Marker{} are required to avoid compiler error.
+		listBlob, err := srcContainerURL.ListBlobsFlatSegment(ctx, marker, azblob.ListBlobsSegmentOptions{
+			Details: azblob.BlobListingDetails{Metadata: true},
+		})
+		if err != nil {
+			return 0, err
+		}
+		marker = listBlob.NextMarker
+
+		for _, item := range listBlob.Segment.BlobItems {
+			names = append(names, LogicalBlobName(item))
+		}
+	}
+
+	errs := make([]error, len(names))
+	runConcurrently(len(names), concurrency, func(i int) {
+		_, err := CopyBlob(ctx, srcContainerURL.NewBlobURL(names[i]), dstContainerURL, &names[i])
+		if err != nil {
+			errs[i] = fmt.Errorf("%s: %w", names[i], err)
+		}
+	})
+
+	failed := compactErrors(errs)
+	if len(failed) > 0 {
+		return len(names) - len(failed), fmt.Errorf("azurestorage: %d of %d blobs failed to copy, first error: %w", len(failed), len(names), failed[0])
+	}
+
+	return len(names), nil
+}
+
+// RenameBlob renames oldName to newName within containerURL. The service has no rename, so the blob is copied
+// server-side, keeping its metadata, content type and tier, and the original is deleted once the copy has completed.
+// The original is left intact when the copy fails, and isn't deleted either when it changed while being copied or
+// has snapshots; the error then says so, and both blobs exist. An existing blob named newName is overwritten.
+func RenameBlob(ctx context.Context, containerURL azblob.ContainerURL, oldName, newName string) error {
+	if oldName == newName {
+		return fmt.Errorf("azurestorage: can't rename %s to itself", oldName)
+	}
+	srcBlobURL := containerURL.NewBlobURL(oldName)
+
+	// Remember the version being copied, so a blob overwritten meanwhile isn't deleted
+	props, err := srcBlobURL.GetProperties(ctx, azblob.BlobAccessConditions{}, azblob.ClientProvidedKeyOptions{})
+	if err != nil {
+		return err
+	}
+
+	_, err = CopyBlob(ctx, srcBlobURL, containerURL, &newName)
+	if err != nil {
+		return err
+	}
+
+	_, err = srcBlobURL.Delete(ctx, azblob.DeleteSnapshotsOptionNone, azblob.BlobAccessConditions{
+		ModifiedAccessConditions: azblob.ModifiedAccessConditions{IfMatch: props.ETag()},
+	})
+	if err != nil {
+		return fmt.Errorf("azurestorage: copied %s to %s but kept the original: %w", oldName, newName, err)
+	}
+
+	return nil
+}
+
+// CopyBlobClassified copies the blob at srcURL, which may be in another account and carry a SAS, to destName in
+// destContainerURL and leaves the destination with exactly the given metadata and index tags. Both are set by the copy
+// request itself, so the destination is never visible without its classification, and the call returns once the copy
+// has completed. Empty metadata removes the source's metadata from the copy.
+func CopyBlobClassified(ctx context.Context, srcURL string, destContainerURL azblob.ContainerURL, destName string, metadata, tags map[string]string) error {
+	source, err := url.Parse(srcURL)
+	if err != nil {
+		return err
+	}
+	if err := ValidateMetadataSize(metadata); err != nil {
+		return err
+	}
+	if err := ValidateBlobTags(tags); err != nil {
+		return err
+	}
+	destURL := destContainerURL.NewBlobURL(destName)
+
+	copyResp, err := destURL.StartCopyFromURL(ctx, *source, metadata, azblob.ModifiedAccessConditions{}, azblob.BlobAccessConditions{}, azblob.DefaultAccessTier, tags)
+	if err != nil {
+		return err
+	}
+	err = waitForBlobCopy(ctx, destURL, copyResp.CopyID(), copyResp.CopyStatus(), DefaultCopyBackoff)
+	if err != nil {
+		return err
+	}
+
+	// A copy given no metadata keeps the source's, which must not leak into the classified blob
+	if len(metadata) == 0 {
+		_, err = destURL.SetMetadata(ctx, azblob.Metadata{}, azblob.BlobAccessConditions{}, azblob.ClientProvidedKeyOptions{})
+	}
+
+	return err
+}
+
+func originalTimestampMetadata(props *azblob.BlobGetPropertiesResponse) azblob.Metadata {
+	// Metadata passed to the copy replaces the source's, so start from the source's own
+	metadata := props.NewMetadata()
+	if _, ok := metadata[OriginalLastModifiedKey]; !ok {
+		metadata[OriginalLastModifiedKey] = props.LastModified().UTC().Format(time.RFC3339)
+	}
+	if _, ok := metadata[OriginalCreatedKey]; !ok {
+		metadata[OriginalCreatedKey] = props.CreationTime().UTC().Format(time.RFC3339)
+	}
+
+	return metadata
+}
+
+func waitForBlobCopy(ctx context.Context, blobURL azblob.BlobURL, copyID string, status azblob.CopyStatusType, backoff CopyBackoff) error {
+	// Poll the destination's properties, less and less often, until the copy identified by copyID is no longer pending
+	for wait := backoff.Initial; status == azblob.CopyStatusPending; wait = backoff.next(wait) {
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+
+		props, err := blobURL.GetProperties(ctx, azblob.BlobAccessConditions{}, azblob.ClientProvidedKeyOptions{})
+		if err != nil {
+			return err
+		}
+		if props.CopyID() != copyID {
+			return fmt.Errorf("azurestorage: copy %s was superseded by copy %s", copyID, props.CopyID())
+		}
+		status = props.CopyStatus()
+		if status == azblob.CopyStatusFailed || status == azblob.CopyStatusAborted {
+			return fmt.Errorf("azurestorage: copy %s %s: %s", copyID, status, props.CopyStatusDescription())
+		}
+	}
+
+	return nil
+}
+
+type rehydratePriorityKey struct{}
+
+// RehydrateBlobViaCopy rehydrates the archived blob archivedName by copying it to onlineName in targetTier (Hot or
+// Cool), leaving the archived original untouched. It returns once the copy completes, which for a Standard priority
+// rehydration can take up to 15 hours; cancel ctx to stop waiting. priority is only sent for a container obtained from
+// GetBlobContainer, as the azblob SDK always starts copies without one.
+func RehydrateBlobViaCopy(ctx context.Context, containerURL azblob.ContainerURL, archivedName, onlineName string, targetTier azblob.AccessTierType, priority azblob.RehydratePriorityType) error {
+	if targetTier != azblob.AccessTierHot && targetTier != azblob.AccessTierCool {
+		return fmt.Errorf("azurestorage: cannot rehydrate to access tier %q", targetTier)
+	}
+
+	archivedURL := containerURL.NewBlobURL(archivedName)
+	onlineURL := containerURL.NewBlobURL(onlineName)
+
+	if priority != azblob.RehydratePriorityNone {
+		ctx = context.WithValue(ctx, rehydratePriorityKey{}, priority)
+	}
+	copyResp, err := onlineURL.StartCopyFromURL(ctx, archivedURL.URL(), azblob.Metadata{}, azblob.ModifiedAccessConditions{}, azblob.BlobAccessConditions{}, targetTier, nil)
+	if err != nil {
+		return err
+	}
+
+	return waitForBlobCopy(ctx, onlineURL, copyResp.CopyID(), copyResp.CopyStatus(), DefaultCopyBackoff)
+}
+
+// newRehydratePriorityPolicyFactory adds the priority set by RehydrateBlobViaCopy or RehydrateBlob to its copy or
+// set-tier request.
+func newRehydratePriorityPolicyFactory() pipeline.Factory {
+	return pipeline.FactoryFunc(func(next pipeline.Policy, po *pipeline.PolicyOptions) pipeline.PolicyFunc {
+		return func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+			if priority, ok := ctx.Value(rehydratePriorityKey{}).(azblob.RehydratePriorityType); ok && (request.Header.Get("x-ms-copy-source") != "" || request.URL.Query().Get("comp") == "tier") {
+				request.Header.Set("x-ms-rehydrate-priority", string(priority))
+			}
+			return next.Do(ctx, request)
+		}
+	})
+}
@@ -0,0 +1,88 @@
+package azurestorage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+)
+
+// ================================================================================================================================================
+// Azure Storage - BLOB Synchronous Copy
+// ================================================================================================================================================
+
+const (
+	copySourceSASLifetime = 15 * time.Minute // Validity of the read SAS added to the source of a synchronous copy
+)
+
+// CopyBlobSync copies srcName to destName with a synchronous Copy Blob From URL, returning only once the copy is
+// complete. The service limits such copies to source blobs of up to 256 MiB; use CopyBlobWithTier for larger ones.
+// The service reads the source of a synchronous copy as an anonymous client, so the source is signed with a read SAS,
+// valid for 15 minutes, using credential: the source account's SharedKeyCredential, or a UserDelegationCredential for
+// a service authorised with Azure AD. credential may be nil when srcContainerURL already carries a SAS with read
+// access, as it must for a source in another account reached without its key.
+func CopyBlobSync(ctx context.Context, srcContainerURL azblob.ContainerURL, srcName string, destContainerURL azblob.ContainerURL, destName string, credential azblob.StorageAccountCredential) error {
+	destBlobURL := destContainerURL.NewBlockBlobURL(destName)
+	source, err := copySourceURL(srcContainerURL, srcName, destBlobURL, credential)
+	if err != nil {
+		return err
+	}
+
+	// Copy the blob, keeping the source's metadata, and check the service reports the copy as finished
+	copyResp, err := destBlobURL.CopyFromURL(ctx, source, azblob.Metadata{}, azblob.ModifiedAccessConditions{}, azblob.BlobAccessConditions{}, nil, azblob.DefaultAccessTier, nil)
+	if err != nil {
+		return err
+	}
+	if copyResp.CopyStatus() != azblob.SyncCopyStatusSuccess {
+		return fmt.Errorf("azurestorage: synchronous copy %s finished with status %q", copyResp.CopyID(), copyResp.CopyStatus())
+	}
+
+	return nil
+}
+
+// copySourceURL returns the URL of srcName that a synchronous copy to destBlobURL reads. Unless the container URL
+// already carries a SAS, the container is signed for reading. The policies of the destination's service rewrite the
+// source on its way out, TenantScope adding the tenant prefix to the container and WithPartitionPrefix the hash to the
+// blob name, so the SAS is signed for the container the copy names once rewritten. A container SAS stays valid
+// whatever name the blob is given.
+func copySourceURL(srcContainerURL azblob.ContainerURL, srcName string, destBlobURL azblob.BlockBlobURL, credential azblob.StorageAccountCredential) (url.URL, error) {
+	containerURL := srcContainerURL.URL()
+	parts := azblob.NewBlobURLParts(containerURL)
+	parts.BlobName = srcName
+	if containerURL.Query().Get("sig") != "" {
+		return parts.URL(), nil
+	}
+	if credential == nil {
+		return url.URL{}, errors.New("azurestorage: the copy source has no SAS and no credential was given to sign one")
+	}
+
+	// Find the container the copy names once the destination's policies have rewritten its source
+	signedURL := containerURL
+	sent, err := resolveRequest(func(ctx context.Context) error {
+		_, err := destBlobURL.CopyFromURL(ctx, parts.URL(), azblob.Metadata{}, azblob.ModifiedAccessConditions{}, azblob.BlobAccessConditions{}, nil, azblob.DefaultAccessTier, nil)
+		return err
+	})
+	if err != nil {
+		return url.URL{}, err
+	}
+	if sent != nil {
+		sourceURL, err := url.Parse(sent.Header.Get("x-ms-copy-source"))
+		if err != nil {
+			return url.URL{}, err
+		}
+		sourceParts := azblob.NewBlobURLParts(*sourceURL)
+		sourceParts.BlobName = ""
+		signedURL = sourceParts.URL()
+	}
+
+	signed, err := signBlobURL(signedURL, credential, azblob.ContainerSASPermissions{Read: true}.String(), time.Now().Add(copySourceSASLifetime))
+	if err != nil {
+		return url.URL{}, err
+	}
+	parts.SAS = azblob.NewBlobURLParts(signed).SAS // The policies rewrite the path, which is left as it is
+
+	return parts.URL(), nil
+}
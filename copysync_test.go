@@ -0,0 +1,95 @@
+package azurestorage
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-blob-go/azblob"
+)
+
+func TestCopySourceURL(t *testing.T) {
+	credential, err := azblob.NewSharedKeyCredential(devStoreAccountName, devStoreAccountKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	containerURL := func(rawURL string) azblob.ContainerURL {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return azblob.NewContainerURL(*u, azblob.NewPipeline(azblob.NewAnonymousCredential(), azblob.PipelineOptions{}))
+	}
+	destBlobURL := containerURL("https://myaccount.blob.core.windows.net/dst").NewBlockBlobURL("copy.txt")
+
+	t.Run("signed with the credential", func(t *testing.T) {
+		source, err := copySourceURL(containerURL("https://myaccount.blob.core.windows.net/src"), "dir/file.txt", destBlobURL, credential)
+		if err != nil {
+			t.Fatalf("copySourceURL() error = %v", err)
+		}
+		query := source.Query()
+		if source.Path != "/src/dir/file.txt" || query.Get("sig") == "" || query.Get("sr") != "c" || query.Get("sp") != "r" {
+			t.Errorf("copySourceURL() = %s, want /src/dir/file.txt with a container read SAS", source.String())
+		}
+	})
+
+	t.Run("caller's SAS kept", func(t *testing.T) {
+		source, err := copySourceURL(containerURL("https://other.blob.core.windows.net/src?sv=2020-04-08&sr=c&sp=r&sig=abc"), "file.txt", destBlobURL, nil)
+		if err != nil {
+			t.Fatalf("copySourceURL() error = %v", err)
+		}
+		if got := source.Query().Get("sig"); got != "abc" {
+			t.Errorf("copySourceURL() sig = %q, want the caller's %q", got, "abc")
+		}
+	})
+
+	t.Run("no SAS and no credential", func(t *testing.T) {
+		if _, err := copySourceURL(containerURL("https://myaccount.blob.core.windows.net/src"), "file.txt", destBlobURL, nil); err == nil {
+			t.Fatal("copySourceURL() error = nil, want an error when the source can't be signed")
+		}
+	})
+}
+
+func TestCopyBlobSyncTenantScopeAndPartitions(t *testing.T) {
+	credential, err := azblob.NewSharedKeyCredential(devStoreAccountName, devStoreAccountKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender := &recordingSender{respond: func(request *http.Request) *http.Response {
+		header := http.Header{}
+		header.Set("x-ms-copy-status", string(azblob.SyncCopyStatusSuccess))
+		return &http.Response{StatusCode: http.StatusAccepted, Header: header}
+	}}
+	u, err := url.Parse("https://" + devStoreAccountName + ".blob.core.windows.net")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := pipeline.NewPipeline([]pipeline.Factory{
+		newTenantScopePolicyFactory("acme-"),
+		newPartitionPrefixPolicyFactory(8),
+		newResolvePolicyFactory(),
+		pipeline.MethodFactoryMarker(),
+	}, pipeline.Options{HTTPSender: sender})
+	serviceURL := azblob.NewServiceURL(*u, p)
+
+	err = CopyBlobSync(context.Background(), serviceURL.NewContainerURL("src"), "file.txt", serviceURL.NewContainerURL("dst"), "copy.txt", credential)
+	if err != nil {
+		t.Fatalf("CopyBlobSync() error = %v", err)
+	}
+
+	// The partition policy reads the source's metadata first
+	if len(sender.requests) != 2 {
+		t.Fatalf("sent %d requests, want a HEAD of the source and the copy", len(sender.requests))
+	}
+	source := sender.requests[1].Header.Get("x-ms-copy-source")
+	sourceURL, err := url.Parse(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "/acme-src/" + partitionPrefix("file.txt", 8) + "file.txt"; sourceURL.Path != want {
+		t.Errorf("copy source path = %q, want %q", sourceURL.Path, want)
+	}
+	checkSignedFor(t, source, credential, "acme-src", "")
+}
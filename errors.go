@@ -0,0 +1,69 @@
+package azurestorage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+)
+
+// ================================================================================================================================================
+// Azure Storage - Operation Errors
+// ================================================================================================================================================
+
+// StorageOpError wraps the error of every request that fails in a service's pipeline, blob or file, with what a
+// support ticket asks for. errors.As still finds the SDK's StorageError, and ThrottledError, underneath it. Errors the
+// package raises before sending anything, such as ErrInvalidTag, are returned as they are.
+type StorageOpError struct {
+	Op          string // The operation, as the method and the restype and comp query parameters: "PUT?comp=block"
+	StatusCode  int    // 0 when no response was received
+	ServiceCode string // The service's x-ms-error-code, such as "BlobNotFound"; empty when it sent none
+	RequestID   string // The service's x-ms-request-id, or the client's request ID when no response was received
+	Err         error
+}
+
+func (e *StorageOpError) Error() string {
+	if e.StatusCode == 0 {
+		return fmt.Sprintf("azurestorage: %s failed (request %s): %v", e.Op, e.RequestID, e.Err)
+	}
+
+	return fmt.Sprintf("azurestorage: %s failed with status %d %s (request %s): %v", e.Op, e.StatusCode, e.ServiceCode, e.RequestID, e.Err)
+}
+
+func (e *StorageOpError) Unwrap() error {
+	return e.Err
+}
+
+// newStorageOpErrorPolicyFactory wraps the errors of the pipeline in a StorageOpError. It goes first, so the error
+// also covers the policies of the package.
+func newStorageOpErrorPolicyFactory() pipeline.Factory {
+	return pipeline.FactoryFunc(func(next pipeline.Policy, po *pipeline.PolicyOptions) pipeline.PolicyFunc {
+		return func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+			response, err := next.Do(ctx, request)
+			if err == nil {
+				return response, nil
+			}
+
+			opErr := &StorageOpError{
+				Op:        operationName(request),
+				RequestID: request.Header.Get("x-ms-client-request-id"),
+				Err:       err,
+			}
+
+			// Both azblob.StorageError and azfile.StorageError carry the failed response
+			var responder interface{ Response() *http.Response }
+			if errors.As(err, &responder) && responder.Response() != nil {
+				raw := responder.Response()
+				opErr.StatusCode = raw.StatusCode
+				opErr.ServiceCode = raw.Header.Get("x-ms-error-code")
+				if id := raw.Header.Get("x-ms-request-id"); id != "" {
+					opErr.RequestID = id
+				}
+			}
+
+			return response, opErr
+		}
+	})
+}
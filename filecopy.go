@@ -0,0 +1,174 @@
+package azurestorage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-storage-file-go/azfile"
+)
+
+// ================================================================================================================================================
+// Azure Storage - File Copy Functions
+// ================================================================================================================================================
+
+const (
+	fileCopySourceSASLifetime = 24 * time.Hour // Validity of the read SAS of a copy's source file, which must last until the copy completes
+)
+
+// CopyDirectory copies the tree below srcDir of srcShareURL to destDir of destShareURL on the service side, without
+// the content passing through this process. The directories are recreated first (existing ones are reused), then
+// at most concurrency file copies run at the same time, each waited for until it completes. Every source file is
+// read through a read SAS, valid for a day, signed with credential, the source account's key. credential may be nil
+// when srcShareURL already carries a SAS with read access. Failed files don't stop the others.
+func CopyDirectory(ctx context.Context, srcShareURL azfile.ShareURL, srcDir string, destShareURL azfile.ShareURL, destDir string, concurrency int, credential *azfile.SharedKeyCredential) error {
+	srcURL := srcShareURL.URL()
+	if credential == nil && srcURL.Query().Get("sig") == "" {
+		return errors.New("azurestorage: the copy source has no SAS and no credential was given to sign one")
+	}
+
+	// Walk the source breadth-first, creating each directory before its children, and collect the files
+	var files []string // Paths relative to srcDir
+	dirs := []string{""}
+	for len(dirs) > 0 {
+		rel := dirs[0]
+		dirs = dirs[1:]
+
+		if err := createFileDirectory(ctx, destShareURL, path.Join(destDir, rel)); err != nil {
+			return fmt.Errorf("%s: %w", path.Join(destDir, rel), err)
+		}
+
+		srcDirURL := getDirectoryURL(srcShareURL, path.Join(srcDir, rel))
+		for marker := (azfile.Marker{}); marker.NotDone(); { // The parentheses around azfile.Marker{} are required to avoid compiler error.
+			listResponse, err := srcDirURL.ListFilesAndDirectoriesSegment(ctx, marker, azfile.ListFilesAndDirectoriesOptions{})
+			if err != nil {
+				return err
+			}
+			marker = listResponse.NextMarker
+
+			for _, fileEntry := range listResponse.FileItems {
+				files = append(files, path.Join(rel, fileEntry.Name))
+			}
+			for _, dirEntry := range listResponse.DirectoryItems {
+				dirs = append(dirs, path.Join(rel, dirEntry.Name))
+			}
+		}
+	}
+
+	errs := make([]error, len(files))
+	runConcurrently(len(files), concurrency, func(i int) {
+		rel := files[i]
+		srcFileURL := getDirectoryURL(srcShareURL, path.Join(srcDir, path.Dir(rel))).NewFileURL(path.Base(rel))
+		destFileURL := getDirectoryURL(destShareURL, path.Join(destDir, path.Dir(rel))).NewFileURL(path.Base(rel))
+
+		source, err := fileCopySourceURL(srcFileURL.URL(), destFileURL, credential)
+		if err == nil {
+			var copyResp *azfile.FileStartCopyResponse
+			copyResp, err = destFileURL.StartCopy(ctx, source, azfile.Metadata{})
+			if err == nil {
+				err = waitForFileCopy(ctx, destFileURL, copyResp.CopyID(), copyResp.CopyStatus(), DefaultCopyBackoff)
+			}
+		}
+		if err != nil {
+			errs[i] = fmt.Errorf("%s: %w", rel, err)
+		}
+	})
+
+	failed := compactErrors(errs)
+	if len(failed) > 0 {
+		return fmt.Errorf("azurestorage: %d of %d files failed to copy, first error: %w", len(failed), len(files), failed[0])
+	}
+
+	return nil
+}
+
+// fileCopySourceURL returns u, the URL of the source file of a copy to destFileURL, signed for reading with credential
+// unless it already carries a SAS. Like copySourceURL, it signs the share the copy names once the policies of the
+// destination's service, TenantScope's included, have rewritten the source.
+func fileCopySourceURL(u url.URL, destFileURL azfile.FileURL, credential *azfile.SharedKeyCredential) (url.URL, error) {
+	if u.Query().Get("sig") != "" {
+		return u, nil
+	}
+
+	signed := azfile.NewFileURLParts(u)
+	sent, err := resolveRequest(func(ctx context.Context) error {
+		_, err := destFileURL.StartCopy(ctx, u, azfile.Metadata{})
+		return err
+	})
+	if err != nil {
+		return url.URL{}, err
+	}
+	if sent != nil {
+		sourceURL, err := url.Parse(sent.Header.Get("x-ms-copy-source"))
+		if err != nil {
+			return url.URL{}, err
+		}
+		signed = azfile.NewFileURLParts(*sourceURL)
+	}
+
+	parts := azfile.NewFileURLParts(u)
+	values := azfile.FileSASSignatureValues{
+		Protocol:    azfile.SASProtocolHTTPS,
+		StartTime:   time.Now().UTC().Add(-sasClockSkew),
+		ExpiryTime:  time.Now().UTC().Add(fileCopySourceSASLifetime),
+		ShareName:   signed.ShareName,
+		FilePath:    signed.DirectoryOrFilePath,
+		Permissions: azfile.FileSASPermissions{Read: true}.String(),
+	}
+	if parts.Scheme == "http" {
+		values.Protocol = azfile.SASProtocolHTTPSandHTTP // The storage emulator only speaks HTTP
+	}
+	sas, err := values.NewSASQueryParameters(credential)
+	if err != nil {
+		return url.URL{}, err
+	}
+	parts.SAS = sas // The policies rewrite the path, which is left as it is
+
+	return parts.URL(), nil
+}
+
+// createFileDirectory creates the directory at dirPath unless it is the root or already exists.
+func createFileDirectory(ctx context.Context, shareURL azfile.ShareURL, dirPath string) error {
+	if strings.Trim(dirPath, "/") == "" {
+		return nil
+	}
+
+	_, err := getDirectoryURL(shareURL, dirPath).Create(ctx, azfile.Metadata{}, azfile.SMBProperties{})
+	var stgErr azfile.StorageError
+	if errors.As(err, &stgErr) && stgErr.ServiceCode() == azfile.ServiceCodeResourceAlreadyExists {
+		return nil
+	}
+
+	return err
+}
+
+func waitForFileCopy(ctx context.Context, fileURL azfile.FileURL, copyID string, status azfile.CopyStatusType, backoff CopyBackoff) error {
+	// Poll the destination's properties, less and less often, until the copy identified by copyID is no longer pending
+	for wait := backoff.Initial; status == azfile.CopyStatusPending; wait = backoff.next(wait) {
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+
+		props, err := fileURL.GetProperties(ctx)
+		if err != nil {
+			return err
+		}
+		if props.CopyID() != copyID {
+			return fmt.Errorf("azurestorage: copy %s was superseded by copy %s", copyID, props.CopyID())
+		}
+		status = props.CopyStatus()
+		if status == azfile.CopyStatusFailed || status == azfile.CopyStatusAborted {
+			return fmt.Errorf("azurestorage: copy %s %s: %s", copyID, status, props.CopyStatusDescription())
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,77 @@
+package azurestorage
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-file-go/azfile"
+)
+
+func TestFileCopySourceURL(t *testing.T) {
+	credential, err := azfile.NewSharedKeyCredential(devStoreAccountName, devStoreAccountKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u, err := url.Parse("https://myaccount.file.core.windows.net/share/dir/file.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	destFileURL := azfile.NewFileURL(*u, azfile.NewPipeline(azfile.NewAnonymousCredential(), azfile.PipelineOptions{}))
+	source, err := fileCopySourceURL(*u, destFileURL, credential)
+	if err != nil {
+		t.Fatalf("fileCopySourceURL() error = %v", err)
+	}
+	query := source.Query()
+	if source.Host != u.Host || source.Path != u.Path || query.Get("sig") == "" || query.Get("sr") != "f" || query.Get("sp") != "r" {
+		t.Errorf("fileCopySourceURL() = %s, want %s with a file read SAS", source.String(), u.Path)
+	}
+}
+
+func TestFileCopySourceURLTenantScope(t *testing.T) {
+	credential, err := azfile.NewSharedKeyCredential(devStoreAccountName, devStoreAccountKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse("https://" + devStoreAccountName + ".file.core.windows.net")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender := &recordingSender{respond: func(request *http.Request) *http.Response {
+		return &http.Response{StatusCode: http.StatusAccepted, Header: http.Header{}}
+	}}
+	p := pipeline.NewPipeline([]pipeline.Factory{newTenantScopePolicyFactory("acme-"), newResolvePolicyFactory(), pipeline.MethodFactoryMarker()}, pipeline.Options{HTTPSender: sender})
+	serviceURL := azfile.NewServiceURL(*u, p)
+
+	srcFileURL := serviceURL.NewShareURL("share").NewRootDirectoryURL().NewFileURL("file.txt")
+	destFileURL := serviceURL.NewShareURL("backup").NewRootDirectoryURL().NewFileURL("file.txt")
+	source, err := fileCopySourceURL(srcFileURL.URL(), destFileURL, credential)
+	if err != nil {
+		t.Fatalf("fileCopySourceURL() error = %v", err)
+	}
+	if len(sender.requests) != 0 {
+		t.Errorf("sent %d requests, want none", len(sender.requests))
+	}
+
+	// The tenant policy adds the prefix to the path; the SAS must already be signed for it
+	if source.Path != "/share/file.txt" {
+		t.Errorf("fileCopySourceURL() path = %q, want %q", source.Path, "/share/file.txt")
+	}
+	sas := azfile.NewFileURLParts(source).SAS
+	want, err := azfile.FileSASSignatureValues{
+		Protocol:    sas.Protocol(),
+		StartTime:   sas.StartTime(),
+		ExpiryTime:  sas.ExpiryTime(),
+		Permissions: sas.Permissions(),
+		ShareName:   "acme-share",
+		FilePath:    "file.txt",
+	}.NewSASQueryParameters(credential)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sas.Signature() != want.Signature() {
+		t.Errorf("SAS of %s isn't signed for share %q", source.String(), "acme-share")
+	}
+}
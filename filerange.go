@@ -0,0 +1,179 @@
+package azurestorage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/Azure/azure-storage-file-go/azfile"
+)
+
+// ================================================================================================================================================
+// Azure Storage - File Range Writer
+// ================================================================================================================================================
+
+// FileRangeWriter queues writes and clears of ranges of a file and sends them to the share when Flush is called.
+// Queued operations are applied in the order they were made: a later operation wins where ranges overlap, adjacent
+// ranges of the same kind are coalesced into one request, and the resulting requests run with bounded concurrency.
+// The file must already exist and be large enough for every range; a range writer never resizes it.
+type FileRangeWriter struct {
+	fileURL     azfile.FileURL
+	concurrency int
+
+	flushMu sync.Mutex // Serialises flushes, so the ranges of one flush can't overtake those of an earlier one
+	mu      sync.Mutex
+	pending []fileRange // Queued operations in the order they were made
+}
+
+type fileRange struct {
+	offset int64
+	count  int64
+	data   []byte // nil for a cleared range
+}
+
+func (r fileRange) end() int64 {
+	return r.offset + r.count
+}
+
+// slice returns the part of r between the file offsets start and end.
+func (r fileRange) slice(start, end int64) fileRange {
+	s := fileRange{offset: start, count: end - start}
+	if r.data != nil {
+		s.data = r.data[start-r.offset : end-r.offset]
+	}
+	return s
+}
+
+// NewFileRangeWriter returns a FileRangeWriter for fileURL that flushes at most concurrency ranges at the same time.
+func NewFileRangeWriter(fileURL azfile.FileURL, concurrency int) *FileRangeWriter {
+	return &FileRangeWriter{fileURL: fileURL, concurrency: concurrency}
+}
+
+// WriteAt queues p to be written at offset. p is copied, so the caller may reuse it straight away.
+func (w *FileRangeWriter) WriteAt(p []byte, offset int64) (int, error) {
+	if offset < 0 {
+		return 0, fmt.Errorf("%w: offset %d is negative", ErrInvalidRange, offset)
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	w.queue(fileRange{offset: offset, count: int64(len(p)), data: append([]byte(nil), p...)})
+	return len(p), nil
+}
+
+// ClearRange queues the count bytes starting at offset to be cleared, releasing their storage.
+func (w *FileRangeWriter) ClearRange(offset, count int64) error {
+	if offset < 0 || count <= 0 {
+		return fmt.Errorf("%w: offset %d, count %d", ErrInvalidRange, offset, count)
+	}
+
+	w.queue(fileRange{offset: offset, count: count})
+	return nil
+}
+
+func (w *FileRangeWriter) queue(r fileRange) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.pending = append(w.pending, r)
+}
+
+// Flush sends every queued operation to the share. Ranges that fail stay queued ahead of anything queued since, so
+// calling Flush again retries them without undoing newer operations.
+func (w *FileRangeWriter) Flush(ctx context.Context) error {
+	w.flushMu.Lock()
+	defer w.flushMu.Unlock()
+
+	w.mu.Lock()
+	ops := w.pending
+	w.pending = nil
+	w.mu.Unlock()
+
+	ranges := coalesceFileRanges(ops)
+	errs := make([]error, len(ranges))
+	runConcurrently(len(ranges), w.concurrency, func(i int) {
+		r := ranges[i]
+		var err error
+		if r.data == nil {
+			_, err = w.fileURL.ClearRange(ctx, r.offset, r.count)
+		} else {
+			_, err = w.fileURL.UploadRange(ctx, r.offset, bytes.NewReader(r.data), nil)
+		}
+		if err != nil {
+			errs[i] = fmt.Errorf("range %d-%d: %w", r.offset, r.end()-1, err)
+		}
+	})
+
+	failed := compactErrors(errs)
+	if len(failed) > 0 {
+		var retry []fileRange
+		for i, err := range errs {
+			if err != nil {
+				retry = append(retry, ranges[i])
+			}
+		}
+		w.mu.Lock()
+		w.pending = append(retry, w.pending...)
+		w.mu.Unlock()
+
+		return fmt.Errorf("azurestorage: %d of %d ranges failed to flush, first error: %w", len(failed), len(ranges), failed[0])
+	}
+
+	return nil
+}
+
+// coalesceFileRanges applies ops in order and returns the non-overlapping ranges they leave, sorted by offset, with
+// adjacent ranges of the same kind merged. Written ranges are kept within the service's limit for a single request.
+func coalesceFileRanges(ops []fileRange) []fileRange {
+	var ranges []fileRange
+	for _, op := range ops {
+		next := make([]fileRange, 0, len(ranges)+2)
+		for _, r := range ranges {
+			if r.end() <= op.offset || r.offset >= op.end() {
+				next = append(next, r)
+				continue
+			}
+			// Keep the parts of r that op doesn't overwrite
+			if r.offset < op.offset {
+				next = append(next, r.slice(r.offset, op.offset))
+			}
+			if r.end() > op.end() {
+				next = append(next, r.slice(op.end(), r.end()))
+			}
+		}
+		next = append(next, op)
+		sort.Slice(next, func(i, j int) bool { return next[i].offset < next[j].offset })
+		ranges = next
+	}
+
+	// Merge adjacent ranges of the same kind, gathering the data of written ones so it is copied only once
+	var merged []fileRange
+	var parts [][][]byte
+	for _, r := range ranges {
+		if n := len(merged); n > 0 && merged[n-1].end() == r.offset && (merged[n-1].data == nil) == (r.data == nil) {
+			merged[n-1].count += r.count
+			parts[n-1] = append(parts[n-1], r.data)
+			continue
+		}
+		merged = append(merged, r)
+		parts = append(parts, [][]byte{r.data})
+	}
+	for i := range merged {
+		if merged[i].data != nil && len(parts[i]) > 1 {
+			merged[i].data = bytes.Join(parts[i], nil)
+		}
+	}
+
+	var split []fileRange
+	for _, r := range merged {
+		for r.data != nil && r.count > azfile.FileMaxUploadRangeBytes {
+			split = append(split, r.slice(r.offset, r.offset+azfile.FileMaxUploadRangeBytes))
+			r = r.slice(r.offset+azfile.FileMaxUploadRangeBytes, r.end())
+		}
+		split = append(split, r)
+	}
+
+	return split
+}
@@ -0,0 +1,173 @@
+package azurestorage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+)
+
+// ================================================================================================================================================
+// Azure Storage - BLOB Lease Lock
+// ================================================================================================================================================
+
+const (
+	lockRetryInterval = time.Second // How often Lock tries again while another holder has the lease
+)
+
+// ErrNotLocked is returned by Unlock when the lock isn't held.
+var ErrNotLocked = errors.New("azurestorage: lock not held")
+
+// BlobLock is a distributed mutex backed by the lease of a blob. While a finite lease is held it is renewed in the
+// background at half its duration; a failed renewal is passed to the onRenewError callback, as the lock may then be
+// lost once the lease expires.
+type BlobLock struct {
+	blobURL       azblob.BlobURL
+	leaseDuration int32 // Seconds, 15 to 60, or -1 for an infinite lease
+	onRenewError  func(err error)
+
+	mu      sync.Mutex
+	leaseID string
+	stop    chan struct{} // Closed by Unlock to end the renewal
+	done    chan struct{} // Closed once the renewal has ended
+}
+
+// NewBlobLock returns a lock on blobName, which is created empty if it doesn't exist. leaseDuration must be a whole
+// number of seconds between 15 and 60, or negative for a lease that never expires (and so is never renewed).
+// onRenewError may be nil.
+func NewBlobLock(containerURL azblob.ContainerURL, blobName string, leaseDuration time.Duration, onRenewError func(err error)) (*BlobLock, error) {
+	seconds := int32(-1)
+	if leaseDuration >= 0 {
+		if leaseDuration%time.Second != 0 || leaseDuration < 15*time.Second || leaseDuration > time.Minute {
+			return nil, fmt.Errorf("azurestorage: lease duration %s must be negative or whole seconds between 15 and 60", leaseDuration)
+		}
+		seconds = int32(leaseDuration / time.Second)
+	}
+
+	return &BlobLock{
+		blobURL:       containerURL.NewBlobURL(blobName),
+		leaseDuration: seconds,
+		onRenewError:  onRenewError,
+	}, nil
+}
+
+// Lock blocks until it acquires the lease or ctx is done. ctx only bounds the acquisition: the renewal and
+// Unlock outlive it, so they don't depend on any caller's context. The wait doesn't hold the lock's mutex; the
+// service's lease is what keeps a second Lock waiting.
+func (l *BlobLock) Lock(ctx context.Context) error {
+	var leaseID string
+	for {
+		lease, err := l.blobURL.AcquireLease(ctx, "", l.leaseDuration, azblob.ModifiedAccessConditions{})
+		if err == nil {
+			leaseID = lease.LeaseID()
+			break
+		}
+
+		var stgErr azblob.StorageError
+		switch {
+		case isBlobNotFound(err):
+			// Create the lock blob; losing the race to create it is fine, the lease decides who holds the lock
+			_, err = l.blobURL.ToBlockBlobURL().Upload(ctx, bytes.NewReader(nil), azblob.BlobHTTPHeaders{}, azblob.Metadata{}, azblob.BlobAccessConditions{
+				ModifiedAccessConditions: azblob.ModifiedAccessConditions{IfNoneMatch: azblob.ETagAny},
+			}, azblob.DefaultAccessTier, nil, azblob.ClientProvidedKeyOptions{})
+			if err != nil && !isConditionNotMet(err) && !(errors.As(err, &stgErr) && stgErr.ServiceCode() == azblob.ServiceCodeBlobAlreadyExists) {
+				return err
+			}
+			continue
+		case errors.As(err, &stgErr) && stgErr.ServiceCode() == azblob.ServiceCodeLeaseAlreadyPresent:
+			// Held by someone else; wait and try again
+		default:
+			return err
+		}
+
+		timer := time.NewTimer(lockRetryInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.leaseID = leaseID
+	l.stop = make(chan struct{})
+	l.done = make(chan struct{})
+	go l.renew(l.leaseID, l.stop, l.done)
+
+	return nil
+}
+
+// renew keeps a finite lease alive until stop is closed.
+func (l *BlobLock) renew(leaseID string, stop, done chan struct{}) {
+	defer close(done)
+	if l.leaseDuration < 0 {
+		return
+	}
+
+	ticker := time.NewTicker(time.Duration(l.leaseDuration) * time.Second / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			_, err := l.blobURL.RenewLease(context.Background(), leaseID, azblob.ModifiedAccessConditions{})
+			if err != nil && l.onRenewError != nil {
+				l.onRenewError(err)
+			}
+		}
+	}
+}
+
+// Unlock stops the renewal and releases the lease.
+func (l *BlobLock) Unlock() error {
+	l.mu.Lock()
+	leaseID, stop, done := l.leaseID, l.stop, l.done
+	l.leaseID = ""
+	l.mu.Unlock()
+
+	if leaseID == "" {
+		return ErrNotLocked
+	}
+	close(stop)
+	<-done
+
+	_, err := l.blobURL.ReleaseLease(context.Background(), leaseID, azblob.ModifiedAccessConditions{})
+	return err
+}
+
+// AcquireBlobLease takes the lease of the blob for duration seconds, 15 to 60, or -1 for a lease that never expires,
+// and returns its ID. Pass the ID in the LeaseAccessConditions of later writes, and to RenewBlobLease and
+// ReleaseBlobLease. For a lock that renews itself, use NewBlobLock.
+func AcquireBlobLease(ctx context.Context, blobURL azblob.BlockBlobURL, duration int32) (leaseID string, err error) {
+	if duration != -1 && (duration < 15 || duration > 60) {
+		return "", fmt.Errorf("azurestorage: lease duration %d must be -1 or between 15 and 60 seconds", duration)
+	}
+
+	lease, err := blobURL.AcquireLease(ctx, "", duration, azblob.ModifiedAccessConditions{})
+	if err != nil {
+		return "", err
+	}
+
+	return lease.LeaseID(), nil
+}
+
+// RenewBlobLease restarts the duration of the lease leaseID, which must still be held.
+func RenewBlobLease(ctx context.Context, blobURL azblob.BlockBlobURL, leaseID string) error {
+	_, err := blobURL.RenewLease(ctx, leaseID, azblob.ModifiedAccessConditions{})
+	return err
+}
+
+// ReleaseBlobLease ends the lease leaseID, so another client can acquire the blob's lease at once.
+func ReleaseBlobLease(ctx context.Context, blobURL azblob.BlockBlobURL, leaseID string) error {
+	_, err := blobURL.ReleaseLease(ctx, leaseID, azblob.ModifiedAccessConditions{})
+	return err
+}
@@ -0,0 +1,190 @@
+package azurestorage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+)
+
+// ================================================================================================================================================
+// Azure Storage - BLOB Media Reader
+// ================================================================================================================================================
+
+var errMediaReaderClosed = errors.New("azurestorage: media reader is closed")
+
+const (
+	mediaChunkSize   = 1024 * 1024 // Bytes fetched by one ranged download
+	mediaCacheChunks = 16          // Chunks kept around, so seeking back within the last 16 MiB read doesn't fetch again
+)
+
+// blobMediaReader reads a blob through a cache of fixed-size chunks. Reading a chunk starts fetching the next one
+// in the background, so sequential playback rarely waits on the network.
+type blobMediaReader struct {
+	ctx     context.Context // The reader outlives OpenBlobMediaReader, and io.Reader has no way to pass a context
+	cancel  context.CancelFunc
+	fetches sync.WaitGroup // Fetcher goroutines still running
+	closed  bool
+	blobURL azblob.BlobURL
+	size    int64
+	etag    azblob.ETag
+	offset  int64
+	chunks  map[int64]*mediaChunk
+	recent  []int64 // Cached chunk indexes, least recently used first
+}
+
+type mediaChunk struct {
+	done chan struct{} // Closed once data or err is set
+	data []byte
+	err  error
+}
+
+// OpenBlobMediaReader returns a reader of the blob and its size, suited to http.ServeContent and media players that
+// issue many small reads and seeks. The reader is pinned to the blob's current ETag: if the blob is overwritten
+// while it is read, reads fail instead of mixing the old and new content. Every download is made with ctx, so
+// cancelling it makes the reader fail. Close the reader when done with it: Close cancels the downloads still in flight,
+// read-ahead included, and waits for them to stop. It isn't safe for concurrent use.
+func OpenBlobMediaReader(ctx context.Context, containerURL azblob.ContainerURL, blobName string) (io.ReadSeekCloser, int64, error) {
+	blobURL := containerURL.NewBlobURL(blobName)
+
+	props, err := blobURL.GetProperties(ctx, azblob.BlobAccessConditions{}, azblob.ClientProvidedKeyOptions{})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	r := &blobMediaReader{
+		ctx:     ctx,
+		cancel:  cancel,
+		blobURL: blobURL,
+		size:    props.ContentLength(),
+		etag:    props.ETag(),
+		chunks:  map[int64]*mediaChunk{},
+	}
+	return r, r.size, nil
+}
+
+func (r *blobMediaReader) Read(p []byte) (int, error) {
+	if r.closed {
+		return 0, errMediaReaderClosed
+	}
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+
+	index := r.offset / mediaChunkSize
+	chunk := r.chunk(index)
+	if (index+1)*mediaChunkSize < r.size {
+		r.chunk(index + 1) // Read ahead
+	}
+
+	<-chunk.done
+	if chunk.err != nil {
+		r.forget(index) // Let a later read try again
+		return 0, chunk.err
+	}
+
+	n := copy(p, chunk.data[r.offset-index*mediaChunkSize:])
+	r.offset += int64(n)
+	return n, nil
+}
+
+func (r *blobMediaReader) Seek(offset int64, whence int) (int64, error) {
+	if r.closed {
+		return 0, errMediaReaderClosed
+	}
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return 0, errors.New("azurestorage: invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("azurestorage: negative position")
+	}
+
+	r.offset = offset
+	return offset, nil
+}
+
+// Close cancels the downloads in flight and waits for their goroutines to exit. Closing twice is harmless.
+func (r *blobMediaReader) Close() error {
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+
+	r.cancel()
+	r.fetches.Wait()
+	r.chunks, r.recent = nil, nil // Release the cached data
+
+	return nil
+}
+
+// chunk returns the chunk at index, starting to fetch it if it isn't cached.
+func (r *blobMediaReader) chunk(index int64) *mediaChunk {
+	if chunk, ok := r.chunks[index]; ok {
+		r.touch(index)
+		return chunk
+	}
+
+	chunk := &mediaChunk{done: make(chan struct{})}
+	r.chunks[index] = chunk
+	r.touch(index)
+	if len(r.recent) > mediaCacheChunks {
+		r.forget(r.recent[0])
+	}
+
+	r.fetches.Add(1)
+	go func(start int64) {
+		defer r.fetches.Done()
+		defer close(chunk.done)
+
+		count := int64(mediaChunkSize)
+		if start+count > r.size {
+			count = r.size - start
+		}
+		get, err := r.blobURL.Download(r.ctx, start, count, azblob.BlobAccessConditions{
+			ModifiedAccessConditions: azblob.ModifiedAccessConditions{IfMatch: r.etag},
+		}, false, azblob.ClientProvidedKeyOptions{})
+		if err != nil {
+			chunk.err = err
+			return
+		}
+
+		body := get.Body(azblob.RetryReaderOptions{MaxRetryRequests: 3})
+		defer body.Close()
+		chunk.data, chunk.err = io.ReadAll(body)
+		if chunk.err == nil && int64(len(chunk.data)) != count {
+			chunk.err = io.ErrUnexpectedEOF
+		}
+	}(index * mediaChunkSize)
+
+	return chunk
+}
+
+// touch marks the chunk at index as the most recently used.
+func (r *blobMediaReader) touch(index int64) {
+	for i, cached := range r.recent {
+		if cached == index {
+			r.recent = append(r.recent[:i], r.recent[i+1:]...)
+			break
+		}
+	}
+	r.recent = append(r.recent, index)
+}
+
+func (r *blobMediaReader) forget(index int64) {
+	delete(r.chunks, index)
+	for i, cached := range r.recent {
+		if cached == index {
+			r.recent = append(r.recent[:i], r.recent[i+1:]...)
+			break
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package azurestorage
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-blob-go/azblob"
+)
+
+func TestBlobMediaReaderCloseStopsFetches(t *testing.T) {
+	downloading := make(chan struct{}, 2)
+	sender := pipeline.FactoryFunc(func(next pipeline.Policy, po *pipeline.PolicyOptions) pipeline.PolicyFunc {
+		return func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+			if request.Method == http.MethodHead {
+				header := http.Header{}
+				header.Set("Content-Length", "3145728") // Three chunks, so the first read also reads ahead
+				header.Set("ETag", `"0x1"`)
+				return pipeline.NewHTTPResponse(&http.Response{StatusCode: http.StatusOK, Header: header, Body: http.NoBody, Request: request.Request}), nil
+			}
+
+			// Hang like a stalled download until the reader gives up on it
+			downloading <- struct{}{}
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+	})
+	u, err := url.Parse("https://account.blob.core.windows.net/container")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := pipeline.NewPipeline([]pipeline.Factory{pipeline.MethodFactoryMarker()}, pipeline.Options{HTTPSender: sender})
+
+	reader, size, err := OpenBlobMediaReader(context.Background(), azblob.NewContainerURL(*u, p), "movie.mp4")
+	if err != nil {
+		t.Fatalf("OpenBlobMediaReader() error = %v", err)
+	}
+	if size != 3*mediaChunkSize {
+		t.Fatalf("size = %d, want %d", size, 3*mediaChunkSize)
+	}
+
+	// Start the fetch of the first chunk and its read-ahead without blocking on them
+	r := reader.(*blobMediaReader)
+	r.chunk(0)
+	r.chunk(1)
+	<-downloading
+	<-downloading
+
+	closed := make(chan error)
+	go func() { closed <- reader.Close() }()
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Fatalf("Close() error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close() didn't return, want the downloads cancelled")
+	}
+
+	if _, err := reader.Read(make([]byte, 1)); err == nil {
+		t.Error("Read() after Close() error = nil, want an error")
+	}
+}
@@ -0,0 +1,156 @@
+package azurestorage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+)
+
+// ================================================================================================================================================
+// Azure Storage - Metadata Functions
+// ================================================================================================================================================
+
+// maxMetadataSize is the service's limit on the combined size of all metadata names and values of a blob or container.
+const maxMetadataSize = 8 * 1024
+
+// ErrMetadataTooLarge is returned (wrapped with the actual size) when metadata exceeds the service's 8 KiB limit.
+var ErrMetadataTooLarge = errors.New("azurestorage: metadata too large")
+
+// ErrInvalidMetadataKey is returned (wrapped with the offending key) for a metadata name that isn't a valid C#
+// identifier, as the service requires.
+var ErrInvalidMetadataKey = errors.New("azurestorage: invalid metadata key")
+
+// ValidateMetadataKeys checks that every metadata name is a C# identifier: an ASCII letter or underscore followed by
+// letters, digits or underscores. Names are case-insensitive, so two that differ only in case are rejected too.
+func ValidateMetadataKeys(metadata map[string]string) error {
+	seen := map[string]string{}
+	for key := range metadata {
+		if !isIdentifier(key) {
+			return fmt.Errorf("%w: %q must start with a letter or underscore and contain only letters, digits and underscores", ErrInvalidMetadataKey, key)
+		}
+		if other, ok := seen[strings.ToLower(key)]; ok {
+			return fmt.Errorf("%w: %q and %q differ only in case", ErrInvalidMetadataKey, key, other)
+		}
+		seen[strings.ToLower(key)] = key
+	}
+
+	return nil
+}
+
+func isIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, c := range s {
+		switch {
+		case c == '_', 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z':
+		case '0' <= c && c <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// ValidateMetadataSize checks metadata against the 8 KiB limit before it is sent, so an oversized set fails with a
+// descriptive error instead of the service's generic 400 response.
+func ValidateMetadataSize(metadata map[string]string) error {
+	size := 0
+	for key, value := range metadata {
+		size += len(key) + len(value)
+	}
+
+	if size > maxMetadataSize {
+		return fmt.Errorf("%w: %d bytes of names and values exceeds the limit of %d bytes", ErrMetadataTooLarge, size, maxMetadataSize)
+	}
+
+	return nil
+}
+
+// CompareAndSetBlobMetadata sets the metadata key of the blob to newValue only if it currently holds expected (an
+// absent key holds ""). It reports false, without an error, when the value differs or when another writer changed
+// the blob between the read and the write, so two workers can't both claim a blob through the same flag.
+func CompareAndSetBlobMetadata(ctx context.Context, containerURL azblob.ContainerURL, blobName, key, expected, newValue string) (bool, error) {
+	blobURL := containerURL.NewBlobURL(blobName)
+
+	props, err := blobURL.GetProperties(ctx, azblob.BlobAccessConditions{}, azblob.ClientProvidedKeyOptions{})
+	if err != nil {
+		return false, err
+	}
+	metadata := props.NewMetadata()
+	if metadata[key] != expected {
+		return false, nil
+	}
+
+	// SetMetadata replaces the whole set, so the other keys are sent back unchanged
+	metadata[key] = newValue
+	if err := ValidateMetadataSize(metadata); err != nil {
+		return false, err
+	}
+	_, err = blobURL.SetMetadata(ctx, metadata, azblob.BlobAccessConditions{
+		ModifiedAccessConditions: azblob.ModifiedAccessConditions{IfMatch: props.ETag()},
+	}, azblob.ClientProvidedKeyOptions{})
+	if err != nil {
+		if isConditionNotMet(err) {
+			return false, nil // Lost the race to another writer
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+// SetBlobMetadata replaces the metadata of the blob with metadata, after checking its names and its size.
+func SetBlobMetadata(ctx context.Context, blobURL azblob.BlockBlobURL, metadata map[string]string) error {
+	if err := ValidateMetadataKeys(metadata); err != nil {
+		return err
+	}
+	if err := ValidateMetadataSize(metadata); err != nil {
+		return err
+	}
+
+	_, err := blobURL.SetMetadata(ctx, metadata, azblob.BlobAccessConditions{}, azblob.ClientProvidedKeyOptions{})
+	return err
+}
+
+// GetBlobMetadata returns the metadata of the blob. Names come back in lower case, as they travel as HTTP headers.
+func GetBlobMetadata(ctx context.Context, blobURL azblob.BlockBlobURL) (map[string]string, error) {
+	props, err := blobURL.GetProperties(ctx, azblob.BlobAccessConditions{}, azblob.ClientProvidedKeyOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	return props.NewMetadata(), nil
+}
+
+// SetContainerMetadata replaces the metadata of the container with metadata, after checking it like SetBlobMetadata.
+func SetContainerMetadata(ctx context.Context, containerURL azblob.ContainerURL, metadata map[string]string) error {
+	if err := ValidateMetadataKeys(metadata); err != nil {
+		return err
+	}
+	if err := ValidateMetadataSize(metadata); err != nil {
+		return err
+	}
+
+	_, err := containerURL.SetMetadata(ctx, metadata, azblob.ContainerAccessConditions{})
+	return err
+}
+
+// GetContainerMetadata returns the metadata of the container, with names in lower case like GetBlobMetadata.
+func GetContainerMetadata(ctx context.Context, containerURL azblob.ContainerURL) (map[string]string, error) {
+	props, err := containerURL.GetProperties(ctx, azblob.LeaseAccessConditions{})
+	if err != nil {
+		return nil, err
+	}
+
+	return props.NewMetadata(), nil
+}
+
+func isConditionNotMet(err error) bool {
+	var stgErr azblob.StorageError
+	return errors.As(err, &stgErr) && stgErr.ServiceCode() == azblob.ServiceCodeConditionNotMet
+}
@@ -0,0 +1,82 @@
+package azurestorage
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+)
+
+// ================================================================================================================================================
+// Azure Storage - Metrics
+// ================================================================================================================================================
+
+// Recorder receives the metrics of every operation sent through a service created with WithMetrics. Record is called
+// from the goroutine that issued the operation, so implementations must be safe for concurrent use.
+type Recorder interface {
+	Record(m OperationMetrics)
+}
+
+// OperationMetrics describes one REST operation, measured across all of its retries.
+type OperationMetrics struct {
+	Operation  string        // Method plus the restype/comp query that identifies the REST operation, e.g. "PUT?comp=block"
+	StatusCode int           // Status of the final response; 0 when no response was received
+	Duration   time.Duration // Time until the response headers arrived, including retries
+	Bytes      int64         // Body bytes sent for uploads or announced by the response for downloads
+	Err        error         // Outcome of the operation; nil on success
+}
+
+func newMetricsPolicyFactory(recorder Recorder) pipeline.Factory {
+	return pipeline.FactoryFunc(func(next pipeline.Policy, po *pipeline.PolicyOptions) pipeline.PolicyFunc {
+		return func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+			if isResolving(ctx) {
+				return next.Do(ctx, request) // Never sent, so there is nothing to measure
+			}
+
+			start := time.Now()
+			response, err := next.Do(ctx, request)
+
+			m := OperationMetrics{
+				Operation: operationName(request),
+				Duration:  time.Since(start),
+				Bytes:     request.ContentLength,
+				Err:       err,
+			}
+
+			var httpResponse *http.Response
+			if response != nil {
+				httpResponse = response.Response()
+			}
+			var respErr interface{ Response() *http.Response }
+			if httpResponse == nil && errors.As(err, &respErr) {
+				httpResponse = respErr.Response()
+			}
+			if httpResponse != nil {
+				m.StatusCode = httpResponse.StatusCode
+				if request.Method == http.MethodGet && err == nil && httpResponse.ContentLength > 0 {
+					m.Bytes = httpResponse.ContentLength
+				}
+			}
+
+			recorder.Record(m)
+			return response, err
+		}
+	})
+}
+
+func operationName(request pipeline.Request) string {
+	name := request.Method
+
+	query := request.URL.Query()
+	sep := "?"
+	for _, key := range []string{"restype", "comp"} {
+		if value := query.Get(key); value != "" {
+			name += sep + key + "=" + value
+			sep = "&"
+		}
+	}
+
+	return name
+}
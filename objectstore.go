@@ -0,0 +1,113 @@
+package azurestorage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+)
+
+// ================================================================================================================================================
+// Azure Storage - S3-style Object Facade
+// ================================================================================================================================================
+
+// ErrObjectNotFound is returned (wrapping the storage error) by GetObject when the bucket or key doesn't exist.
+var ErrObjectNotFound = errors.New("azurestorage: object not found")
+
+// ObjectStore exposes blob storage with S3-style naming: a bucket is a container and a key is a blob name.
+type ObjectStore struct {
+	serviceURL azblob.ServiceURL
+}
+
+// ObjectInfo describes an object returned by GetObject.
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	ContentType  string
+	ETag         string
+	LastModified time.Time
+	Metadata     map[string]string
+}
+
+// NewObjectStore returns an ObjectStore over the blob service of serviceURL. Every option of the service, such as
+// retries or a tenant scope, applies to the store's operations.
+func NewObjectStore(serviceURL azblob.ServiceURL) ObjectStore {
+	return ObjectStore{serviceURL: serviceURL}
+}
+
+// PutObject uploads size bytes read from r to bucket/key, replacing any existing object. A negative size uploads
+// everything until r returns io.EOF. When r ends before size bytes, nothing is committed and an error wrapping
+// io.ErrUnexpectedEOF is returned, as S3 rejects a body shorter than its Content-Length.
+func (s ObjectStore) PutObject(ctx context.Context, bucket, key string, r io.Reader, size int64, contentType string) error {
+	blobURL := s.serviceURL.NewContainerURL(bucket).NewBlockBlobURL(key)
+
+	if size >= 0 {
+		r = &sizedReader{r: r, size: size, remaining: size}
+	}
+
+	// Stream the object in blocks so the size doesn't have to fit in a single upload request
+	_, err := azblob.UploadStreamToBlockBlob(ctx, r, blobURL, azblob.UploadStreamToBlockBlobOptions{
+		BlobHTTPHeaders: azblob.BlobHTTPHeaders{ContentType: contentType},
+	})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// sizedReader reads exactly size bytes from r, failing when r ends early. The failure isn't io.ErrUnexpectedEOF
+// itself, which the SDK's streaming upload takes for the end of the stream and would commit the blob on.
+type sizedReader struct {
+	r         io.Reader
+	size      int64
+	remaining int64
+}
+
+func (s *sizedReader) Read(p []byte) (int, error) {
+	if s.remaining <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > s.remaining {
+		p = p[:s.remaining]
+	}
+
+	n, err := s.r.Read(p)
+	s.remaining -= int64(n)
+	if err == io.EOF && s.remaining > 0 {
+		err = fmt.Errorf("azurestorage: object body ended after %d of %d bytes: %w", s.size-s.remaining, s.size, io.ErrUnexpectedEOF)
+	}
+
+	return n, err
+}
+
+// GetObject opens bucket/key for reading. The caller must close the returned body.
+func (s ObjectStore) GetObject(ctx context.Context, bucket, key string) (io.ReadCloser, ObjectInfo, error) {
+	blobURL := s.serviceURL.NewContainerURL(bucket).NewBlobURL(key)
+
+	get, err := blobURL.Download(ctx, 0, azblob.CountToEnd, azblob.BlobAccessConditions{}, false, azblob.ClientProvidedKeyOptions{})
+	if err != nil {
+		var stgErr azblob.StorageError
+		if errors.As(err, &stgErr) {
+			switch stgErr.ServiceCode() {
+			case azblob.ServiceCodeBlobNotFound, azblob.ServiceCodeContainerNotFound:
+				return nil, ObjectInfo{}, fmt.Errorf("%w: %s/%s: %v", ErrObjectNotFound, bucket, key, err)
+			}
+		}
+		return nil, ObjectInfo{}, err
+	}
+
+	info := ObjectInfo{
+		Key:          key,
+		Size:         get.ContentLength(),
+		ContentType:  get.ContentType(),
+		ETag:         string(get.ETag()),
+		LastModified: get.LastModified(),
+		Metadata:     get.NewMetadata(),
+	}
+
+	return get.Body(azblob.RetryReaderOptions{}), info, nil
+}
@@ -0,0 +1,59 @@
+package azurestorage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-blob-go/azblob"
+)
+
+func objectStore(t *testing.T, sender *recordingSender) ObjectStore {
+	t.Helper()
+
+	u, err := url.Parse("https://account.blob.core.windows.net")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := pipeline.NewPipeline([]pipeline.Factory{pipeline.MethodFactoryMarker()}, pipeline.Options{HTTPSender: sender})
+
+	return NewObjectStore(azblob.NewServiceURL(*u, p))
+}
+
+func TestPutObject(t *testing.T) {
+	var uploaded string
+	sender := &recordingSender{respond: func(request *http.Request) *http.Response {
+		body, _ := io.ReadAll(request.Body)
+		uploaded += string(body)
+		return &http.Response{StatusCode: http.StatusCreated, Header: http.Header{}}
+	}}
+
+	err := objectStore(t, sender).PutObject(context.Background(), "bucket", "key", strings.NewReader("hello world"), 5, "text/plain")
+	if err != nil {
+		t.Fatalf("PutObject() error = %v", err)
+	}
+	if !strings.HasPrefix(uploaded, "hello") || strings.Contains(uploaded, "world") {
+		t.Errorf("uploaded %q, want the first 5 bytes only", uploaded)
+	}
+}
+
+func TestPutObjectShortBody(t *testing.T) {
+	sender := &recordingSender{respond: func(request *http.Request) *http.Response {
+		return &http.Response{StatusCode: http.StatusCreated, Header: http.Header{}}
+	}}
+
+	err := objectStore(t, sender).PutObject(context.Background(), "bucket", "key", strings.NewReader("abc"), 10, "text/plain")
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("PutObject() error = %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+	for _, request := range sender.requests {
+		if request.URL.Query().Get("comp") == "blocklist" {
+			t.Errorf("sent %s, want nothing committed", request.URL)
+		}
+	}
+}
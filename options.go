@@ -0,0 +1,209 @@
+package azurestorage
+
+import (
+	"time"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/Azure/azure-storage-file-go/azfile"
+)
+
+// ================================================================================================================================================
+// Azure Storage - Pipeline Options
+// ================================================================================================================================================
+
+// Option customises the request pipeline created by GetBlobService and GetFileService. Every URL derived from the
+// returned ServiceURL (containers, blobs, shares, directories and files) inherits the configured behaviour.
+type Option func(*serviceOptions)
+
+type serviceOptions struct {
+	bandwidthLimit int64                            // Bytes per second shared by every transfer of the service; 0 means unlimited
+	transferLimit  int64                            // Bytes per second of each individual request; 0 means unlimited
+	defaultTiers   map[string]azblob.AccessTierType // Access tier applied to new block blobs, keyed by container name
+	readOnly       bool                             // Reject every request that could modify the account
+	recorder       Recorder                         // Receives the metrics of every operation
+	tenantPrefix   string                           // Prefix confining every container and share name to one tenant
+	partitionBits  int                              // Bits of the name hash prefixed to every blob name; 0 means none
+	retry          RetryOptions                     // How failed requests are retried; zero fields keep the SDK's defaults
+	requestLogger  func(entry RequestLog)           // Receives every try of every request
+	telemetry      string                           // Application ID prepended to the User-Agent
+	err            error                            // First invalid option, returned by the function creating the service
+}
+
+// RetryOptions tunes how the pipeline retries a failed request, with exponential backoff. Any field left zero keeps
+// the SDK's default: 4 tries of up to a minute each, and a 4 second delay growing to at most 2 minutes.
+type RetryOptions struct {
+	MaxTries      int32         // Tries per request, the first included; 1 disables retries
+	TryTimeout    time.Duration // Maximum time for a single try
+	RetryDelay    time.Duration // Delay before the first retry, growing exponentially for each following one
+	MaxRetryDelay time.Duration // Upper bound of the delay between retries
+}
+
+// WithRetryOptions retries failed requests as configured by retry instead of the SDK's defaults. Raise MaxTries on
+// flaky networks; lower TryTimeout for latency-sensitive callers that would rather fail fast.
+func WithRetryOptions(retry RetryOptions) Option {
+	return func(o *serviceOptions) {
+		o.retry = retry
+	}
+}
+
+// WithBandwidthLimit caps the combined upload and download throughput of the service to bytesPerSec.
+func WithBandwidthLimit(bytesPerSec int64) Option {
+	return func(o *serviceOptions) {
+		o.bandwidthLimit = bytesPerSec
+	}
+}
+
+// WithPerTransferLimit caps the throughput of every single request to bytesPerSec. Combined with WithBandwidthLimit,
+// concurrent transfers share the service's total while none of them takes more than its own cap; for example 10
+// downloads under a 100 MB/s total and a 20 MB/s per-transfer cap. Use WithCallBandwidthLimit to cap a whole call.
+func WithPerTransferLimit(bytesPerSec int64) Option {
+	return func(o *serviceOptions) {
+		o.transferLimit = bytesPerSec
+	}
+}
+
+// WithDefaultAccessTier uploads every new block blob in containerName to tier unless the upload asks for a tier itself.
+// Azure only supports a default tier for the whole account, so the tier is added to each upload request instead.
+// Pass the option once for every container that needs a default.
+func WithDefaultAccessTier(containerName string, tier azblob.AccessTierType) Option {
+	return func(o *serviceOptions) {
+		if o.defaultTiers == nil {
+			o.defaultTiers = map[string]azblob.AccessTierType{}
+		}
+		o.defaultTiers[containerName] = tier
+	}
+}
+
+// WithReadOnly makes every mutating request (uploads, deletes, creates and property or metadata changes) fail with
+// ErrReadOnly before it reaches the network. Only GET and HEAD requests are let through.
+func WithReadOnly(readOnly bool) Option {
+	return func(o *serviceOptions) {
+		o.readOnly = readOnly
+	}
+}
+
+// WithMetrics reports the name, duration, transferred bytes and outcome of every operation to recorder.
+func WithMetrics(recorder Recorder) Option {
+	return func(o *serviceOptions) {
+		o.recorder = recorder
+	}
+}
+
+func newServiceOptions(options []Option) (serviceOptions, error) {
+	o := serviceOptions{}
+	for _, option := range options {
+		option(&o)
+	}
+
+	return o, o.err
+}
+
+// fail records err as the reason the options are invalid, keeping the first one.
+func (o *serviceOptions) fail(err error) {
+	if o.err == nil {
+		o.err = err
+	}
+}
+
+// apiFactories returns the policies placed closest to the API; they run once per operation, before it is signed.
+func (o serviceOptions) apiFactories() []pipeline.Factory {
+	f := []pipeline.Factory{
+		newStorageOpErrorPolicyFactory(), // Outermost, so every error callers see carries the request ID
+		newThrottlePolicyFactory(),       // Next, so callers see throttling errors wrapped
+	}
+	if o.recorder != nil {
+		f = append(f, newMetricsPolicyFactory(o.recorder))
+	}
+	if o.readOnly {
+		f = append(f, newReadOnlyPolicyFactory())
+	}
+	if len(o.defaultTiers) > 0 {
+		f = append(f, newDefaultTierPolicyFactory(o.defaultTiers))
+	}
+	if o.tenantPrefix != "" {
+		f = append(f, newTenantScopePolicyFactory(o.tenantPrefix)) // After the default tiers, which are keyed by unprefixed names
+	}
+
+	return f
+}
+
+// wireFactories returns the policies placed closest to the wire; they run once for every try of a request.
+func (o serviceOptions) wireFactories() []pipeline.Factory {
+	// The bandwidth policy is always present, as a call can bring its own limit with WithCallBandwidthLimit.
+	var global *rateLimiter
+	if o.bandwidthLimit > 0 {
+		global = newRateLimiter(o.bandwidthLimit)
+	}
+
+	// The progress policy is always present too, for calls made with WithProgress.
+	f := []pipeline.Factory{newBandwidthPolicyFactory(global, o.transferLimit), newProgressPolicyFactory()}
+	if o.requestLogger != nil {
+		f = append(f, newRequestLogPolicyFactory(o.requestLogger)) // Last, so the duration is the service's latency alone
+	}
+
+	return f
+}
+
+func newBlobPipeline(credential azblob.Credential, options []Option) (pipeline.Pipeline, error) {
+	o, err := newServiceOptions(options)
+	if err != nil {
+		return nil, err
+	}
+
+	// This mirrors azblob.NewPipeline, leaving room for the package's own policies.
+	// Closest to API goes first; closest to the wire goes last.
+	f := o.apiFactories()
+	f = append(f,
+		newArchivedPolicyFactory(),
+		newRehydratePriorityPolicyFactory(), // Always present, as the rehydrate functions bring their priority with the call
+	)
+	if o.partitionBits > 0 {
+		f = append(f, newPartitionPrefixPolicyFactory(o.partitionBits)) // Blobs only; file paths are left alone
+	}
+	f = append(f,
+		newResolvePolicyFactory(), // After every policy rewriting the request, so the URLs signed by the package match
+		azblob.NewTelemetryPolicyFactory(azblob.TelemetryOptions{Value: o.telemetry}),
+		azblob.NewUniqueRequestIDPolicyFactory(),
+		azblob.NewRetryPolicyFactory(azblob.RetryOptions{
+			MaxTries:      o.retry.MaxTries,
+			TryTimeout:    o.retry.TryTimeout,
+			RetryDelay:    o.retry.RetryDelay,
+			MaxRetryDelay: o.retry.MaxRetryDelay,
+		}),
+		credential, // The credential must appear close to the wire so it signs any changes made by the policies above
+		azblob.NewRequestLogPolicyFactory(azblob.RequestLogOptions{}),
+		pipeline.MethodFactoryMarker(), // Indicates at what stage in the pipeline the method factory is invoked
+	)
+	f = append(f, o.wireFactories()...)
+
+	return pipeline.NewPipeline(f, pipeline.Options{}), nil
+}
+
+func newFilePipeline(credential azfile.Credential, options []Option) (pipeline.Pipeline, error) {
+	o, err := newServiceOptions(options)
+	if err != nil {
+		return nil, err
+	}
+
+	// This mirrors azfile.NewPipeline, leaving room for the package's own policies.
+	// Closest to API goes first; closest to the wire goes last.
+	f := o.apiFactories()
+	f = append(f,
+		newResolvePolicyFactory(), // After every policy rewriting the request, so the URLs signed by the package match
+		azfile.NewTelemetryPolicyFactory(azfile.TelemetryOptions{Value: o.telemetry}),
+		azfile.NewUniqueRequestIDPolicyFactory(),
+		azfile.NewRetryPolicyFactory(azfile.RetryOptions{
+			MaxTries:      o.retry.MaxTries,
+			TryTimeout:    o.retry.TryTimeout,
+			RetryDelay:    o.retry.RetryDelay,
+			MaxRetryDelay: o.retry.MaxRetryDelay,
+		}),
+		credential, // The credential must appear close to the wire so it signs any changes made by the policies above
+		azfile.NewRequestLogPolicyFactory(azfile.RequestLogOptions{}),
+		pipeline.MethodFactoryMarker(), // Indicates at what stage in the pipeline the method factory is invoked
+	)
+	f = append(f, o.wireFactories()...)
+
+	return pipeline.NewPipeline(f, pipeline.Options{}), nil
+}
@@ -0,0 +1,102 @@
+package azurestorage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+)
+
+// ================================================================================================================================================
+// Azure Storage - BLOB Page Functions
+// ================================================================================================================================================
+
+// ErrPageAlignment is returned when a page blob size, offset or length isn't a multiple of the 512-byte page size.
+var ErrPageAlignment = errors.New("azurestorage: not aligned to the 512-byte page size")
+
+// CreatePageBlob creates blobName as a page blob of size bytes, all zero, for random-access writes such as disk images.
+// size must be a multiple of the 512-byte page size, or ErrPageAlignment is returned without sending a request.
+func CreatePageBlob(ctx context.Context, containerURL azblob.ContainerURL, blobName *string, size int64) (azblob.PageBlobURL, error) {
+	if err := checkPageAlignment("size", size); err != nil {
+		return azblob.PageBlobURL{}, err
+	}
+
+	// Create a URL that references a to-be-created page blob in the container.
+	blobURL := containerURL.NewPageBlobURL(*blobName) // Blob names can be mixed case
+
+	// Create the blob with all of its pages zeroed; its size is fixed up front
+	_, err := blobURL.Create(ctx, size, 0, azblob.BlobHTTPHeaders{}, azblob.Metadata{}, azblob.BlobAccessConditions{}, azblob.PremiumPageBlobAccessTierNone, nil, azblob.ClientProvidedKeyOptions{})
+	if err != nil {
+		return azblob.PageBlobURL{}, err
+	}
+
+	return blobURL, nil
+}
+
+// WritePageRange writes the rest of data, from its current position, to the page blob starting at offset. Both offset
+// and the length written must be multiples of 512; data longer than 4 MiB is written with several requests.
+func WritePageRange(ctx context.Context, pageBlobURL azblob.PageBlobURL, offset int64, data io.ReadSeeker) error {
+	if err := checkPageAlignment("offset", offset); err != nil {
+		return err
+	}
+	start, err := data.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	end, err := data.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	length := end - start
+	if err := checkPageAlignment("length", length); err != nil {
+		return err
+	}
+	if _, err := data.Seek(start, io.SeekStart); err != nil {
+		return err
+	}
+
+	// Write the pages in chunks of at most the service's limit for one request
+	buf := make([]byte, azblob.PageBlobMaxUploadPagesBytes)
+	for written := int64(0); written < length; {
+		n, err := io.ReadFull(data, buf)
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return err
+		}
+
+		_, err = pageBlobURL.UploadPages(ctx, offset+written, bytes.NewReader(buf[:n]), azblob.PageBlobAccessConditions{}, nil, azblob.ClientProvidedKeyOptions{})
+		if err != nil {
+			return err
+		}
+		written += int64(n)
+	}
+
+	return nil
+}
+
+// ClearPageRange zeroes count bytes of the page blob starting at offset, releasing their storage. Both offset and count
+// must be multiples of 512.
+func ClearPageRange(ctx context.Context, pageBlobURL azblob.PageBlobURL, offset, count int64) error {
+	if err := checkPageAlignment("offset", offset); err != nil {
+		return err
+	}
+	if err := checkPageAlignment("count", count); err != nil {
+		return err
+	}
+	if count == 0 {
+		return nil
+	}
+
+	_, err := pageBlobURL.ClearPages(ctx, offset, count, azblob.PageBlobAccessConditions{}, azblob.ClientProvidedKeyOptions{})
+	return err
+}
+
+func checkPageAlignment(what string, n int64) error {
+	if n < 0 || n%azblob.PageBlobPageBytes != 0 {
+		return fmt.Errorf("%w: %s %d", ErrPageAlignment, what, n)
+	}
+
+	return nil
+}
@@ -0,0 +1,38 @@
+package azurestorage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-blob-go/azblob"
+)
+
+func TestWritePageRangeFromOffset(t *testing.T) {
+	var written []byte
+	sender := &recordingSender{respond: func(request *http.Request) *http.Response {
+		body, _ := io.ReadAll(request.Body)
+		written = append(written, body...)
+		return &http.Response{StatusCode: http.StatusCreated, Header: http.Header{}}
+	}}
+	u, err := url.Parse("https://account.blob.core.windows.net/container/disk.vhd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := pipeline.NewPipeline([]pipeline.Factory{pipeline.MethodFactoryMarker()}, pipeline.Options{HTTPSender: sender})
+
+	header, page := bytes.Repeat([]byte{'h'}, 100), bytes.Repeat([]byte{'p'}, 512)
+	data := bytes.NewReader(append(header, page...))
+	data.Seek(int64(len(header)), io.SeekStart)
+
+	if err := WritePageRange(context.Background(), azblob.NewPageBlobURL(*u, p), 0, data); err != nil {
+		t.Fatalf("WritePageRange() error = %v", err)
+	}
+	if !bytes.Equal(written, page) {
+		t.Errorf("wrote %q, want the 512 bytes after the offset", written)
+	}
+}
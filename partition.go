@@ -0,0 +1,196 @@
+package azurestorage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-blob-go/azblob"
+)
+
+// ================================================================================================================================================
+// Azure Storage - Partition Prefixes
+// ================================================================================================================================================
+
+// ErrPartitionedPrefix is returned for a listing with a prefix through a service created with WithPartitionPrefix.
+var ErrPartitionedPrefix = errors.New("azurestorage: prefix listings aren't supported with partition prefixes")
+
+// LogicalNameMetadata is the metadata key under which WithPartitionPrefix stores the name a blob was written with,
+// path-escaped because metadata values must be ASCII.
+const LogicalNameMetadata = "logical_name"
+
+// maxPartitionBits caps the prefixes at 4 hex digits.
+const maxPartitionBits = 16
+
+// WithPartitionPrefix spreads blobs across the service's partitions by storing every blob under a short hash of its
+// name followed by a hyphen, so sequential names such as timestamps don't all land in one hot partition. bits (1 to
+// 16) sets the number of distinct prefixes, 2^bits. The package keeps working with the logical names: a blob written
+// as "2024-01-01.log" is stored as, say, "3f-2024-01-01.log" and read back as "2024-01-01.log". Listings return the
+// stored names; list with metadata and use LogicalBlobName to get the logical ones back, as CopyContainer,
+// AuditContainer and OnlyCommittedBlobs do. A logical prefix can't be mapped to the stored names, so listings with a
+// prefix fail with ErrPartitionedPrefix: ListBlobsByPrefix, ListBlobSnapshots, FolderSizes, and ListBlobsHierarchy and
+// ListBlobsDetailed with a prefix. A copy within the account keeps the source's metadata under the new logical name.
+// Changing bits for an existing container makes its blobs unreachable under their logical names, so bits outside 1 to
+// 16 fail the creation of the service rather than pick a layout.
+func WithPartitionPrefix(bits int) Option {
+	return func(o *serviceOptions) {
+		if bits < 1 || bits > maxPartitionBits {
+			// Any other layout than the one meant would leave the blobs already written unreachable
+			o.fail(fmt.Errorf("azurestorage: partition prefix bits %d must be 1 to %d", bits, maxPartitionBits))
+			return
+		}
+		o.partitionBits = bits
+	}
+}
+
+// LogicalBlobName returns the name a listed blob was written with through a service created with WithPartitionPrefix.
+// The listing must include metadata; without it, or for blobs written otherwise, the stored name is returned.
+func LogicalBlobName(item azblob.BlobItemInternal) string {
+	if escaped, ok := item.Metadata[LogicalNameMetadata]; ok {
+		if name, err := url.PathUnescape(escaped); err == nil {
+			return name
+		}
+	}
+
+	return item.Name
+}
+
+// partitionPrefix returns the hash prefix of a logical blob name.
+func partitionPrefix(name string, bits int) string {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+
+	return fmt.Sprintf("%0*x-", (bits+3)/4, h.Sum32()>>(32-bits))
+}
+
+func newPartitionPrefixPolicyFactory(bits int) pipeline.Factory {
+	return pipeline.FactoryFunc(func(next pipeline.Policy, po *pipeline.PolicyOptions) pipeline.PolicyFunc {
+		return func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+			// A logical prefix never matches the stored names, which start with the hash of the whole name
+			query := request.URL.Query()
+			if query.Get("restype") == "container" && query.Get("comp") == "list" && query.Get("prefix") != "" {
+				return nil, ErrPartitionedPrefix
+			}
+
+			name, ok := prefixBlobName(request.URL, bits)
+			if !ok {
+				return next.Do(ctx, request) // Not a request on a single blob
+			}
+
+			comp := request.URL.Query().Get("comp")
+			setsMetadata := request.Method == http.MethodPut && (comp == "" || comp == "blocklist" || comp == "metadata")
+
+			// A copy within the account reads its source under its stored name too
+			if source := request.Header.Get("x-ms-copy-source"); source != "" {
+				sourceURL, err := url.Parse(source)
+				if err != nil {
+					return nil, err
+				}
+				sameAccount := sourceURL.Host == request.URL.Host
+				if sameAccount {
+					if _, ok := prefixBlobName(sourceURL, bits); ok {
+						request.Header.Set("x-ms-copy-source", sourceURL.String())
+					}
+				}
+
+				// A copy without metadata of its own keeps the source's, which any metadata header would replace.
+				// Within the account the source's metadata is sent along with the new logical name; a copy from
+				// another account keeps the source's metadata as it is. A request made by resolveRequest is never sent,
+				// so it doesn't need any.
+				if setsMetadata && comp == "" && !hasMetadataHeaders(request.Header) && !isResolving(ctx) {
+					if !sameAccount {
+						return next.Do(ctx, request)
+					}
+					if err := copySourceMetadata(ctx, next, *sourceURL, request.Header); err != nil {
+						return nil, err
+					}
+				}
+			}
+
+			// Record the logical name whenever the request sets the blob's metadata
+			if setsMetadata {
+				request.Header.Set("x-ms-meta-"+LogicalNameMetadata, url.PathEscape(name))
+			}
+
+			return next.Do(ctx, request)
+		}
+	})
+}
+
+// hasMetadataHeaders reports whether header sets any of the blob's metadata.
+func hasMetadataHeaders(header http.Header) bool {
+	for key := range header {
+		if strings.HasPrefix(strings.ToLower(key), "x-ms-meta-") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// copySourceMetadata reads the metadata of the copy source at sourceURL, already rewritten to its stored name, and
+// adds it to header. The request goes through next, so it is signed and retried like the copy itself; the copy's
+// responder below accepts the 200 of the HEAD request and keeps its headers, and turns any failure into an error.
+func copySourceMetadata(ctx context.Context, next pipeline.Policy, sourceURL url.URL, header http.Header) error {
+	request, err := pipeline.NewRequest(http.MethodHead, sourceURL, nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("x-ms-version", azblob.ServiceVersion)
+
+	response, err := next.Do(ctx, request)
+	if err != nil {
+		return err
+	}
+	for key, values := range response.Response().Header {
+		if strings.HasPrefix(strings.ToLower(key), "x-ms-meta-") {
+			header[key] = values
+		}
+	}
+
+	return nil
+}
+
+// prefixBlobName rewrites the blob named by u's path to its stored name, returning the logical name. It returns false
+// when the path names no blob.
+func prefixBlobName(u *url.URL, bits int) (string, bool) {
+	parts := azblob.NewBlobURLParts(*u)
+	if parts.BlobName == "" {
+		return "", false
+	}
+	prefix := partitionPrefix(parts.BlobName, bits)
+
+	// The blob name is everything after the container segment (and the account segment of an IP-style URL)
+	skip := 1
+	if parts.IPEndpointStyleInfo.AccountName != "" {
+		skip = 2
+	}
+	u.Path = insertAfterSegments(u.Path, skip, prefix)
+	if u.RawPath != "" {
+		u.RawPath = insertAfterSegments(u.RawPath, skip, prefix)
+	}
+
+	return parts.BlobName, true
+}
+
+// insertAfterSegments inserts s into path after its first n segments.
+func insertAfterSegments(path string, n int, s string) string {
+	start := 0
+	if strings.HasPrefix(path, "/") {
+		start = 1
+	}
+	for ; n > 0; n-- {
+		i := strings.Index(path[start:], "/")
+		if i == -1 {
+			return path
+		}
+		start += i + 1
+	}
+
+	return path[:start] + s + path[start:]
+}
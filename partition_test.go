@@ -0,0 +1,127 @@
+package azurestorage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-blob-go/azblob"
+)
+
+// recordingSender answers every request with respond and keeps the requests it was sent.
+type recordingSender struct {
+	requests []*http.Request
+	respond  func(request *http.Request) *http.Response
+}
+
+func (s *recordingSender) New(next pipeline.Policy, po *pipeline.PolicyOptions) pipeline.Policy {
+	return pipeline.PolicyFunc(func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+		s.requests = append(s.requests, request.Request)
+
+		response := s.respond(request.Request)
+		response.Request = request.Request
+		if response.Body == nil {
+			response.Body = io.NopCloser(strings.NewReader(""))
+		}
+
+		return pipeline.NewHTTPResponse(response), nil
+	})
+}
+
+func partitionedContainer(t *testing.T, sender *recordingSender) azblob.ContainerURL {
+	t.Helper()
+
+	u, err := url.Parse("https://account.blob.core.windows.net/container")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := pipeline.NewPipeline([]pipeline.Factory{newPartitionPrefixPolicyFactory(8), pipeline.MethodFactoryMarker()}, pipeline.Options{HTTPSender: sender})
+
+	return azblob.NewContainerURL(*u, p)
+}
+
+func TestPartitionPrefixCopyKeepsSourceMetadata(t *testing.T) {
+	sender := &recordingSender{respond: func(request *http.Request) *http.Response {
+		if request.Method == http.MethodHead {
+			header := http.Header{}
+			header.Set("x-ms-meta-owner", "alice")
+			header.Set("x-ms-meta-"+LogicalNameMetadata, "old.txt")
+			return &http.Response{StatusCode: http.StatusOK, Header: header}
+		}
+		return &http.Response{StatusCode: http.StatusAccepted, Header: http.Header{}}
+	}}
+	containerURL := partitionedContainer(t, sender)
+
+	_, err := containerURL.NewBlobURL("new.txt").StartCopyFromURL(context.Background(), containerURL.NewBlobURL("old.txt").URL(),
+		azblob.Metadata{}, azblob.ModifiedAccessConditions{}, azblob.BlobAccessConditions{}, azblob.DefaultAccessTier, nil)
+	if err != nil {
+		t.Fatalf("StartCopyFromURL() error = %v", err)
+	}
+
+	if len(sender.requests) != 2 {
+		t.Fatalf("sent %d requests, want a HEAD of the source and the copy", len(sender.requests))
+	}
+	head, copyRequest := sender.requests[0], sender.requests[1]
+	if want := "/container/" + partitionPrefix("old.txt", 8) + "old.txt"; head.URL.Path != want {
+		t.Errorf("HEAD path = %q, want %q", head.URL.Path, want)
+	}
+	if got := copyRequest.Header.Get("x-ms-meta-owner"); got != "alice" {
+		t.Errorf("copy x-ms-meta-owner = %q, want %q", got, "alice")
+	}
+	if got := copyRequest.Header.Get("x-ms-meta-" + LogicalNameMetadata); got != "new.txt" {
+		t.Errorf("copy logical name = %q, want %q", got, "new.txt")
+	}
+}
+
+func TestPartitionPrefixCopyWithOwnMetadata(t *testing.T) {
+	sender := &recordingSender{respond: func(request *http.Request) *http.Response {
+		return &http.Response{StatusCode: http.StatusAccepted, Header: http.Header{}}
+	}}
+	containerURL := partitionedContainer(t, sender)
+
+	_, err := containerURL.NewBlobURL("new.txt").StartCopyFromURL(context.Background(), containerURL.NewBlobURL("old.txt").URL(),
+		azblob.Metadata{"owner": "bob"}, azblob.ModifiedAccessConditions{}, azblob.BlobAccessConditions{}, azblob.DefaultAccessTier, nil)
+	if err != nil {
+		t.Fatalf("StartCopyFromURL() error = %v", err)
+	}
+
+	if len(sender.requests) != 1 {
+		t.Fatalf("sent %d requests, want only the copy", len(sender.requests))
+	}
+	if got := sender.requests[0].Header.Get("x-ms-meta-owner"); got != "bob" {
+		t.Errorf("copy x-ms-meta-owner = %q, want %q", got, "bob")
+	}
+}
+
+func TestPartitionPrefixRejectsPrefixListing(t *testing.T) {
+	sender := &recordingSender{respond: func(request *http.Request) *http.Response {
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}}
+	}}
+	containerURL := partitionedContainer(t, sender)
+
+	_, err := ListBlobsByPrefix(context.Background(), containerURL, "2024-", 0)
+	if !errors.Is(err, ErrPartitionedPrefix) {
+		t.Fatalf("ListBlobsByPrefix() error = %v, want %v", err, ErrPartitionedPrefix)
+	}
+	if len(sender.requests) != 0 {
+		t.Errorf("sent %d requests, want none", len(sender.requests))
+	}
+}
+
+func TestWithPartitionPrefixValidatesBits(t *testing.T) {
+	accountName := "myaccount"
+	accountKey := devStoreAccountKey
+	blobServiceURL := "https://%s.blob.core.windows.net"
+
+	for _, bits := range []int{-1, 0, 1, 8, 16, 17, 20} {
+		_, err := GetBlobService(&accountName, &accountKey, &blobServiceURL, WithPartitionPrefix(bits))
+		if valid := bits >= 1 && bits <= 16; valid != (err == nil) {
+			t.Errorf("WithPartitionPrefix(%d): GetBlobService() error = %v, want valid = %v", bits, err, valid)
+		}
+	}
+}
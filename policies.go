@@ -0,0 +1,100 @@
+package azurestorage
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-blob-go/azblob"
+)
+
+// ================================================================================================================================================
+// Azure Storage - Pipeline Policies
+// ================================================================================================================================================
+
+// ErrReadOnly is returned for any mutating request made through a service created with WithReadOnly(true).
+var ErrReadOnly = errors.New("azurestorage: service is read-only")
+
+func newReadOnlyPolicyFactory() pipeline.Factory {
+	return pipeline.FactoryFunc(func(next pipeline.Policy, po *pipeline.PolicyOptions) pipeline.PolicyFunc {
+		return func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+			switch request.Method {
+			case http.MethodGet, http.MethodHead:
+				return next.Do(ctx, request)
+			}
+
+			return nil, ErrReadOnly
+		}
+	})
+}
+
+func newDefaultTierPolicyFactory(tiers map[string]azblob.AccessTierType) pipeline.Factory {
+	return pipeline.FactoryFunc(func(next pipeline.Policy, po *pipeline.PolicyOptions) pipeline.PolicyFunc {
+		return func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+			// Only block blobs have a standard access tier: a Put Blob of a block blob or a Put Block List commits one.
+			isBlockBlobPut := request.Header.Get("x-ms-blob-type") == string(azblob.BlobBlockBlob) && request.URL.Query().Get("comp") == ""
+			isBlockListPut := request.URL.Query().Get("comp") == "blocklist"
+
+			if request.Method == http.MethodPut && (isBlockBlobPut || isBlockListPut) && request.Header.Get("x-ms-access-tier") == "" {
+				parts := azblob.NewBlobURLParts(*request.URL)
+				if tier, ok := tiers[parts.ContainerName]; ok && parts.BlobName != "" {
+					request.Header.Set("x-ms-access-tier", string(tier))
+				}
+			}
+
+			return next.Do(ctx, request)
+		}
+	})
+}
+
+// resolveKey marks the context of a request made by resolveRequest.
+type resolveKey struct{}
+
+// resolvedRequest ends a request made by resolveRequest once the package's policies have rewritten it, carrying the
+// request as it would be signed and sent.
+type resolvedRequest struct {
+	request *http.Request
+}
+
+func (r *resolvedRequest) Error() string {
+	return "azurestorage: request resolved without being sent"
+}
+
+// resolveRequest makes a request with do and returns it as the package's policies rewrite it, for example with a
+// tenant prefix added to its container name, without sending it. Code that signs URLs itself needs it to sign the
+// names the service will see. The context given to do is already cancelled, so a pipeline not created by this package,
+// which rewrites nothing, fails the request at once and nil is returned.
+func resolveRequest(do func(ctx context.Context) error) (*http.Request, error) {
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), resolveKey{}, true))
+	cancel()
+
+	err := do(ctx)
+	var resolved *resolvedRequest
+	if errors.As(err, &resolved) {
+		return resolved.request, nil
+	}
+	if err == nil || errors.Is(err, context.Canceled) {
+		return nil, nil
+	}
+
+	return nil, err // Refused by a policy, such as a tenant scope's for a special container
+}
+
+// isResolving reports whether ctx is that of a request made by resolveRequest, which never reaches the service.
+func isResolving(ctx context.Context) bool {
+	return ctx.Value(resolveKey{}) != nil
+}
+
+// newResolvePolicyFactory ends the requests made by resolveRequest. It follows every policy that rewrites requests.
+func newResolvePolicyFactory() pipeline.Factory {
+	return pipeline.FactoryFunc(func(next pipeline.Policy, po *pipeline.PolicyOptions) pipeline.PolicyFunc {
+		return func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+			if isResolving(ctx) {
+				return nil, &resolvedRequest{request: request.Request}
+			}
+
+			return next.Do(ctx, request)
+		}
+	})
+}
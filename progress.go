@@ -0,0 +1,102 @@
+package azurestorage
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+)
+
+// ================================================================================================================================================
+// Azure Storage - Transfer Progress
+// ================================================================================================================================================
+
+type progressKey struct{}
+
+// progressTracker sums the bytes moved by every request of one call.
+type progressTracker struct {
+	mu       sync.Mutex
+	total    int64
+	progress func(bytesTransferred int64)
+}
+
+// add counts n more bytes, or takes back -n bytes of a failed try, and reports the new total. Reports are serialized,
+// so progress is never called concurrently even when a call transfers blocks in parallel.
+func (t *progressTracker) add(n int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.total += n
+	t.progress(t.total)
+}
+
+// WithProgress returns a copy of ctx that reports the bytes uploaded and downloaded by every transfer made with it,
+// so any upload or download function, single-shot, streaming or parallel, drives a progress bar. progress receives
+// the running total for the whole call; a request that fails and is retried takes its bytes back, so the total can
+// briefly go down. A nil progress returns ctx unchanged, and a context without progress adds no overhead.
+func WithProgress(ctx context.Context, progress func(bytesTransferred int64)) context.Context {
+	if progress == nil {
+		return ctx
+	}
+
+	return context.WithValue(ctx, progressKey{}, &progressTracker{progress: progress})
+}
+
+type progressReadCloser struct {
+	body    io.ReadCloser
+	tracker *progressTracker
+	read    int64
+}
+
+func (r *progressReadCloser) Read(p []byte) (int, error) {
+	n, err := r.body.Read(p)
+	if n > 0 {
+		r.read += int64(n)
+		r.tracker.add(int64(n))
+	}
+
+	return n, err
+}
+
+func (r *progressReadCloser) Close() error {
+	return r.body.Close()
+}
+
+// newProgressPolicyFactory counts the request and response bodies of calls made with WithProgress. It sits next to
+// the wire, so every try of a request is seen.
+func newProgressPolicyFactory() pipeline.Factory {
+	return pipeline.FactoryFunc(func(next pipeline.Policy, po *pipeline.PolicyOptions) pipeline.PolicyFunc {
+		return func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+			tracker, ok := ctx.Value(progressKey{}).(*progressTracker)
+			if !ok {
+				return next.Do(ctx, request)
+			}
+
+			// Count the upload through this try's copy of the request body.
+			var upload *progressReadCloser
+			if request.Body != nil && request.Body != http.NoBody {
+				request = request.Copy()
+				upload = &progressReadCloser{body: request.Body, tracker: tracker}
+				request.Body = upload
+			}
+
+			// An error status fails this try as much as a network error does; neither body carries content
+			response, err := next.Do(ctx, request)
+			if err != nil || response == nil || response.Response() == nil || response.Response().StatusCode >= http.StatusBadRequest {
+				if upload != nil && upload.read > 0 {
+					tracker.add(-upload.read) // The try will be sent again, or the call fails
+				}
+				return response, err
+			}
+
+			// Count the download as the caller reads the response body.
+			if response.Response().Body != nil {
+				response.Response().Body = &progressReadCloser{body: response.Response().Body, tracker: tracker}
+			}
+
+			return response, nil
+		}
+	})
+}
@@ -0,0 +1,55 @@
+package azurestorage
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/Azure/azure-storage-file-go/azfile"
+)
+
+// ================================================================================================================================================
+// Azure Storage - Range Helpers
+// ================================================================================================================================================
+
+// CountToEnd is the count that makes the package's range helpers read from the offset to the end of the blob or file.
+// azblob.CountToEnd (0) is accepted too, so both SDK conventions mean the same thing on either service.
+const CountToEnd int64 = -1
+
+// ErrInvalidRange is returned for a negative offset or a count that is neither positive nor CountToEnd.
+var ErrInvalidRange = errors.New("azurestorage: invalid range")
+
+func validateRange(offset, count int64) error {
+	if offset < 0 {
+		return fmt.Errorf("%w: offset %d is negative", ErrInvalidRange, offset)
+	}
+	if count < CountToEnd {
+		return fmt.Errorf("%w: count %d is negative, use CountToEnd to read to the end", ErrInvalidRange, count)
+	}
+
+	return nil
+}
+
+// blobRangeCount converts count into azblob's convention, where 0 means to the end.
+func blobRangeCount(offset, count int64) (int64, error) {
+	if err := validateRange(offset, count); err != nil {
+		return 0, err
+	}
+	if count == CountToEnd {
+		return azblob.CountToEnd, nil
+	}
+
+	return count, nil
+}
+
+// fileRangeCount converts count into azfile's convention, where -1 means to the end and 0 is an empty range.
+func fileRangeCount(offset, count int64) (int64, error) {
+	if err := validateRange(offset, count); err != nil {
+		return 0, err
+	}
+	if count == 0 {
+		return azfile.CountToEnd, nil
+	}
+
+	return count, nil
+}
@@ -0,0 +1,160 @@
+package azurestorage
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+)
+
+// ================================================================================================================================================
+// Azure Storage - Bandwidth Throttling
+// ================================================================================================================================================
+
+// rateLimiter is a token bucket where one token is one byte. Tokens may go negative; the caller that overdraws the
+// bucket waits until it has been refilled, so concurrent transfers share the rate between them.
+type rateLimiter struct {
+	mu       sync.Mutex
+	rate     float64 // Tokens added per second
+	capacity float64 // Maximum tokens kept while the bucket is idle (one second of traffic)
+	tokens   float64
+	last     time.Time
+}
+
+func newRateLimiter(bytesPerSec int64) *rateLimiter {
+	return &rateLimiter{
+		rate:     float64(bytesPerSec),
+		capacity: float64(bytesPerSec),
+		tokens:   float64(bytesPerSec),
+		last:     time.Now(),
+	}
+}
+
+// reserve takes n tokens from the bucket and returns how long the caller must wait before using them.
+func (l *rateLimiter) reserve(n int) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.tokens = math.Min(l.capacity, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+
+	l.tokens -= float64(n)
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+// chunk is the largest read handed out at once, so a single Read can't monopolise the bucket.
+func (l *rateLimiter) chunk() int {
+	if l.capacity < 1 {
+		return 1
+	}
+	return int(l.capacity)
+}
+
+// waitAll takes n tokens from every limiter and waits for the slowest of them, so a transfer respects each level of
+// a limiter hierarchy (the service-wide bucket and the caps of the call and the request) at the same time.
+func waitAll(ctx context.Context, limiters []*rateLimiter, n int) error {
+	var delay time.Duration
+	for _, l := range limiters {
+		if d := l.reserve(n); d > delay {
+			delay = d
+		}
+	}
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+type throttledReadCloser struct {
+	ctx      context.Context
+	body     io.ReadCloser
+	limiters []*rateLimiter
+}
+
+func (r *throttledReadCloser) Read(p []byte) (int, error) {
+	for _, l := range r.limiters {
+		if max := l.chunk(); len(p) > max {
+			p = p[:max]
+		}
+	}
+
+	n, err := r.body.Read(p)
+	if n > 0 {
+		if waitErr := waitAll(r.ctx, r.limiters, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+
+	return n, err
+}
+
+func (r *throttledReadCloser) Close() error {
+	return r.body.Close()
+}
+
+type callLimiterKey struct{}
+
+// WithCallBandwidthLimit returns a copy of ctx that caps the combined throughput of every transfer made with it to
+// bytesPerSec, on top of the service's WithBandwidthLimit and WithPerTransferLimit. All the requests of one call share
+// the cap, including the parallel block uploads of a large blob, so it limits the call as a whole.
+func WithCallBandwidthLimit(ctx context.Context, bytesPerSec int64) context.Context {
+	return context.WithValue(ctx, callLimiterKey{}, newRateLimiter(bytesPerSec))
+}
+
+// newBandwidthPolicyFactory throttles request and response bodies through a hierarchy of token buckets: global is
+// shared by the whole service, the call's bucket comes from WithCallBandwidthLimit and perTransfer caps each request.
+// Any level may be absent (nil global, 0 perTransfer).
+func newBandwidthPolicyFactory(global *rateLimiter, perTransfer int64) pipeline.Factory {
+	return pipeline.FactoryFunc(func(next pipeline.Policy, po *pipeline.PolicyOptions) pipeline.PolicyFunc {
+		return func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+			var limiters []*rateLimiter
+			if global != nil {
+				limiters = append(limiters, global)
+			}
+			if call, ok := ctx.Value(callLimiterKey{}).(*rateLimiter); ok {
+				limiters = append(limiters, call)
+			}
+			if perTransfer > 0 {
+				limiters = append(limiters, newRateLimiter(perTransfer))
+			}
+			if len(limiters) == 0 {
+				return next.Do(ctx, request)
+			}
+
+			// Throttle the upload by wrapping this try's copy of the request body.
+			if request.Body != nil && request.Body != http.NoBody {
+				request = request.Copy()
+				request.Body = &throttledReadCloser{ctx: ctx, body: request.Body, limiters: limiters}
+			}
+
+			response, err := next.Do(ctx, request)
+			if err != nil {
+				return response, err
+			}
+
+			// Throttle the download by wrapping the response body the caller reads from.
+			if response != nil && response.Response() != nil && response.Response().Body != nil {
+				response.Response().Body = &throttledReadCloser{ctx: ctx, body: response.Response().Body, limiters: limiters}
+			}
+
+			return response, nil
+		}
+	})
+}
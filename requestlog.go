@@ -0,0 +1,75 @@
+package azurestorage
+
+import (
+	"context"
+	"time"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+)
+
+// ================================================================================================================================================
+// Azure Storage - Request Logging
+// ================================================================================================================================================
+
+// RequestLog describes one try of a request sent through a service created with WithRequestLogger.
+type RequestLog struct {
+	Method     string
+	URL        string        // The request URL, with any SAS signature redacted
+	StatusCode int           // 0 when no response was received
+	RequestID  string        // The service's x-ms-request-id, to quote in support tickets; empty without a response
+	Duration   time.Duration // Time until the response headers arrived
+	Err        error         // Network or timeout failure of the try; an error status is reported in StatusCode only
+}
+
+// WithRequestLogger passes every try of every request to logger once its response headers arrive, so each storage
+// call can be captured by a structured logger. Retries are reported separately. logger is called from the goroutine
+// that issued the request, so it must be safe for concurrent use.
+func WithRequestLogger(logger func(entry RequestLog)) Option {
+	return func(o *serviceOptions) {
+		o.requestLogger = logger
+	}
+}
+
+// WithTelemetry prepends appID to the User-Agent of every request. The service records it in its logs, so an
+// application's traffic can be told apart in the storage analytics logs.
+func WithTelemetry(appID string) Option {
+	return func(o *serviceOptions) {
+		o.telemetry = appID
+	}
+}
+
+func newRequestLogPolicyFactory(logger func(entry RequestLog)) pipeline.Factory {
+	return pipeline.FactoryFunc(func(next pipeline.Policy, po *pipeline.PolicyOptions) pipeline.PolicyFunc {
+		return func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+			start := time.Now()
+			response, err := next.Do(ctx, request)
+
+			entry := RequestLog{
+				Method:   request.Method,
+				URL:      redactedURL(request),
+				Duration: time.Since(start),
+				Err:      err,
+			}
+			if response != nil && response.Response() != nil {
+				entry.StatusCode = response.Response().StatusCode
+				entry.RequestID = response.Response().Header.Get("x-ms-request-id")
+			}
+
+			logger(entry)
+			return response, err
+		}
+	})
+}
+
+// redactedURL returns the URL of request with the signature of a SAS replaced, so logs don't hand out access.
+func redactedURL(request pipeline.Request) string {
+	u := *request.URL
+	query := u.Query()
+	if query.Get("sig") == "" {
+		return u.String()
+	}
+	query.Set("sig", "REDACTED")
+	u.RawQuery = query.Encode()
+
+	return u.String()
+}
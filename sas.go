@@ -0,0 +1,130 @@
+package azurestorage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+)
+
+// ================================================================================================================================================
+// Azure Storage - Shared Access Signatures
+// ================================================================================================================================================
+
+const (
+	sasClockSkew = 5 * time.Minute // How far back a SAS's start time is set, so clocks running behind accept it at once
+)
+
+// GenerateBlobSAS returns blobURL signed with credential, granting permissions until expiry to anyone holding it,
+// ready to hand to a browser. For a download link pass BlobSASPermissions{Read: true}. credential is the account's
+// SharedKeyCredential, or a UserDelegationCredential from ServiceURL.GetUserDelegationCredential for services
+// authorised with Azure AD. The URL keeps blobURL's host, so links for sovereign clouds, custom endpoints and the
+// storage emulator work too. The link is HTTPS-only, except on an HTTP endpoint such as the emulator's, and valid
+// from a few minutes ago, to allow for clock skew between this host and the service. For a service created with
+// TenantScope or WithPartitionPrefix, the link names the blob as stored, prefixes included, and grants nothing outside
+// the tenant; a special container such as $web fails with ErrTenantScope.
+func GenerateBlobSAS(blobURL azblob.BlobURL, credential azblob.StorageAccountCredential, permissions azblob.BlobSASPermissions, expiry time.Time) (string, error) {
+	if azblob.NewBlobURLParts(blobURL.URL()).BlobName == "" {
+		return "", errors.New("azurestorage: a blob SAS needs the URL of a blob")
+	}
+
+	stored, err := storedURL(blobURL.URL(), func(ctx context.Context) error {
+		_, err := blobURL.GetProperties(ctx, azblob.BlobAccessConditions{}, azblob.ClientProvidedKeyOptions{})
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	u, err := signBlobURL(stored, credential, permissions.String(), expiry)
+	if err != nil {
+		return "", err
+	}
+
+	return u.String(), nil
+}
+
+// GenerateContainerSAS returns containerURL signed like GenerateBlobSAS, granting permissions on every blob in the
+// container: combine Read and List for a client that browses it, or Create and Write for browser uploads confined to
+// it. Append a blob name to the URL's path to address one of its blobs, the stored name under WithPartitionPrefix.
+// Under TenantScope the link names the container with its tenant prefix and grants nothing outside the tenant.
+func GenerateContainerSAS(containerURL azblob.ContainerURL, credential azblob.StorageAccountCredential, permissions azblob.ContainerSASPermissions, expiry time.Time) (string, error) {
+	if azblob.NewBlobURLParts(containerURL.URL()).ContainerName == "" {
+		return "", errors.New("azurestorage: a container SAS needs the URL of a container")
+	}
+
+	stored, err := storedURL(containerURL.URL(), func(ctx context.Context) error {
+		_, err := containerURL.GetProperties(ctx, azblob.LeaseAccessConditions{})
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	u, err := signBlobURL(stored, credential, permissions.String(), expiry)
+	if err != nil {
+		return "", err
+	}
+
+	return u.String(), nil
+}
+
+// storedURL returns u with the path of the request made by do on u as the service's policies send it: with the
+// tenant and partition prefixes the service adds. The query of the request, which names the operation, is left out.
+func storedURL(u url.URL, do func(ctx context.Context) error) (url.URL, error) {
+	resolved, err := resolveRequest(do)
+	if err != nil {
+		return url.URL{}, err
+	}
+	if resolved == nil {
+		return u, nil // The pipeline adds no prefixes
+	}
+
+	u.Path, u.RawPath = resolved.URL.Path, resolved.URL.RawPath
+	return u, nil
+}
+
+// signBlobURL signs u, the URL of a blob, a blob snapshot, or a container when it names no blob, which sets the SAS's
+// resource type. Any SAS u already carries is replaced.
+func signBlobURL(u url.URL, credential azblob.StorageAccountCredential, permissions string, expiry time.Time) (url.URL, error) {
+	if credential == nil {
+		return url.URL{}, errors.New("azurestorage: a SAS needs a credential to sign it")
+	}
+	now := time.Now().UTC()
+	if !expiry.After(now) {
+		return url.URL{}, fmt.Errorf("azurestorage: SAS expiry %s is not in the future", expiry.UTC().Format(time.RFC3339))
+	}
+
+	parts := azblob.NewBlobURLParts(u)
+	values := azblob.BlobSASSignatureValues{
+		Protocol:      azblob.SASProtocolHTTPS,
+		StartTime:     now.Add(-sasClockSkew),
+		ExpiryTime:    expiry.UTC(),
+		ContainerName: parts.ContainerName,
+		BlobName:      parts.BlobName,
+		Permissions:   permissions,
+	}
+	if parts.Scheme == "http" {
+		values.Protocol = azblob.SASProtocolHTTPSandHTTP // The storage emulator only speaks HTTP
+	}
+	if parts.Snapshot != "" {
+		snapshot, err := time.Parse(azblob.SnapshotTimeFormat, parts.Snapshot)
+		if err != nil {
+			return url.URL{}, err
+		}
+		values.SnapshotTime = snapshot
+	}
+
+	sas, err := values.NewSASQueryParameters(credential)
+	if err != nil {
+		return url.URL{}, err
+	}
+
+	// Rebuild the URL from its parts so the blob name is escaped properly
+	parts.SAS = sas
+
+	return parts.URL(), nil
+}
@@ -0,0 +1,163 @@
+package azurestorage
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+)
+
+func TestGenerateBlobSASKeepsHost(t *testing.T) {
+	credential, err := azblob.NewSharedKeyCredential(devStoreAccountName, devStoreAccountKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		blobURL   string
+		wantHost  string
+		wantProto string
+	}{
+		{"public cloud", "https://myaccount.blob.core.windows.net/container/dir/file.txt", "myaccount.blob.core.windows.net", "https"},
+		{"sovereign cloud", "https://myaccount.blob.core.chinacloudapi.cn/container/dir/file.txt", "myaccount.blob.core.chinacloudapi.cn", "https"},
+		{"emulator", "http://127.0.0.1:10000/devstoreaccount1/container/dir/file.txt", "127.0.0.1:10000", "https,http"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := url.Parse(tt.blobURL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			blobURL := azblob.NewBlobURL(*u, azblob.NewPipeline(azblob.NewAnonymousCredential(), azblob.PipelineOptions{}))
+
+			signed, err := GenerateBlobSAS(blobURL, credential, azblob.BlobSASPermissions{Read: true}, time.Now().Add(time.Hour))
+			if err != nil {
+				t.Fatalf("GenerateBlobSAS() error = %v", err)
+			}
+
+			got, err := url.Parse(signed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Host != tt.wantHost || got.Path != u.Path {
+				t.Errorf("GenerateBlobSAS() = %s, want host %s and path %s", signed, tt.wantHost, u.Path)
+			}
+			query := got.Query()
+			if query.Get("sig") == "" || query.Get("sr") != "b" || query.Get("spr") != tt.wantProto {
+				t.Errorf("GenerateBlobSAS() query = %s, want a blob SAS for %s", got.RawQuery, tt.wantProto)
+			}
+		})
+	}
+}
+
+func TestGenerateBlobSASRejectsPastExpiry(t *testing.T) {
+	credential, err := azblob.NewSharedKeyCredential(devStoreAccountName, devStoreAccountKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse("https://myaccount.blob.core.windows.net/container/file.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	blobURL := azblob.NewBlobURL(*u, azblob.NewPipeline(azblob.NewAnonymousCredential(), azblob.PipelineOptions{}))
+
+	if _, err := GenerateBlobSAS(blobURL, credential, azblob.BlobSASPermissions{Read: true}, time.Now().Add(-time.Minute)); err == nil {
+		t.Fatal("GenerateBlobSAS() error = nil, want an error for an expiry in the past")
+	}
+}
+
+// tenantService returns a service scoped to the tenant "acme", on the emulator's account so SAS signatures can be
+// checked against its well-known key.
+func tenantService(t *testing.T) (azblob.ServiceURL, *azblob.SharedKeyCredential) {
+	t.Helper()
+
+	accountName, accountKey, blobServiceURL := devStoreAccountName, devStoreAccountKey, "https://%s.blob.core.windows.net"
+	serviceURL, err := GetBlobService(&accountName, &accountKey, &blobServiceURL, TenantScope("acme"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	credential, err := azblob.NewSharedKeyCredential(devStoreAccountName, devStoreAccountKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return serviceURL, credential
+}
+
+// checkSignedFor fails t unless signed carries a valid SAS signature for containerName and blobName, which is empty
+// for a container SAS.
+func checkSignedFor(t *testing.T, signed string, credential *azblob.SharedKeyCredential, containerName, blobName string) {
+	t.Helper()
+
+	u, err := url.Parse(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sas := azblob.NewBlobURLParts(*u).SAS
+	want, err := azblob.BlobSASSignatureValues{
+		Protocol:      sas.Protocol(),
+		StartTime:     sas.StartTime(),
+		ExpiryTime:    sas.ExpiryTime(),
+		Permissions:   sas.Permissions(),
+		ContainerName: containerName,
+		BlobName:      blobName,
+	}.NewSASQueryParameters(credential)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sas.Signature() != want.Signature() {
+		t.Errorf("SAS of %s isn't signed for container %q and blob %q", signed, containerName, blobName)
+	}
+}
+
+func TestGenerateBlobSASTenantScope(t *testing.T) {
+	serviceURL, credential := tenantService(t)
+
+	// "bob-invoices" must stay a container of tenant acme, not become tenant bob's "invoices"
+	name := "bob-invoices"
+	blobURL := GetBlobContainer(serviceURL, &name).NewBlobURL("file.txt")
+	signed, err := GenerateBlobSAS(blobURL, credential, azblob.BlobSASPermissions{Read: true}, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("GenerateBlobSAS() error = %v", err)
+	}
+
+	u, err := url.Parse(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "/acme-bob-invoices/file.txt"; u.Path != want {
+		t.Errorf("GenerateBlobSAS() path = %q, want %q", u.Path, want)
+	}
+	checkSignedFor(t, signed, credential, "acme-bob-invoices", "file.txt")
+
+	special := "$web"
+	webURL := GetBlobContainer(serviceURL, &special).NewBlobURL("index.html")
+	if _, err := GenerateBlobSAS(webURL, credential, azblob.BlobSASPermissions{Read: true}, time.Now().Add(time.Hour)); !errors.Is(err, ErrTenantScope) {
+		t.Errorf("GenerateBlobSAS() of $web error = %v, want %v", err, ErrTenantScope)
+	}
+}
+
+func TestGenerateContainerSASTenantScope(t *testing.T) {
+	serviceURL, credential := tenantService(t)
+
+	name := "bob-invoices"
+	signed, err := GenerateContainerSAS(GetBlobContainer(serviceURL, &name), credential, azblob.ContainerSASPermissions{Read: true, List: true}, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("GenerateContainerSAS() error = %v", err)
+	}
+
+	u, err := url.Parse(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "/acme-bob-invoices"; u.Path != want {
+		t.Errorf("GenerateContainerSAS() path = %q, want %q", u.Path, want)
+	}
+	if query := u.Query(); query.Get("restype") != "" || query.Get("sr") != "c" {
+		t.Errorf("GenerateContainerSAS() query = %s, want only a container SAS", u.RawQuery)
+	}
+	checkSignedFor(t, signed, credential, "acme-bob-invoices", "")
+}
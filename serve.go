@@ -0,0 +1,177 @@
+package azurestorage
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+)
+
+// ================================================================================================================================================
+// Azure Storage - BLOB HTTP Serving
+// ================================================================================================================================================
+
+// ServeBlob answers r with the content of blobName, like http.ServeContent does for local files. It honours
+// If-None-Match and If-Modified-Since with 304 Not Modified, and a single byte range (with If-Range) with 206 Partial
+// Content, downloading only the requested bytes. A request for several ranges is answered with the whole blob.
+func ServeBlob(w http.ResponseWriter, r *http.Request, containerURL azblob.ContainerURL, blobName string) {
+	ctx := r.Context() // Stop downloading when the client goes away
+	blobURL := containerURL.NewBlobURL(blobName)
+
+	props, err := blobURL.GetProperties(ctx, azblob.BlobAccessConditions{}, azblob.ClientProvidedKeyOptions{})
+	if err != nil {
+		serveBlobError(w, err)
+		return
+	}
+	size := props.ContentLength()
+	etag := string(props.ETag())
+	lastModified := props.LastModified().UTC()
+
+	header := w.Header()
+	header.Set("ETag", etag)
+	header.Set("Last-Modified", lastModified.Format(http.TimeFormat))
+	header.Set("Accept-Ranges", "bytes")
+
+	if notModified(r, etag, lastModified) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	if contentType := props.ContentType(); contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+
+	// Serve the whole blob unless a single, still valid range was asked for
+	offset, count, status := int64(0), size, http.StatusOK
+	if rangeHeader := r.Header.Get("Range"); rangeHeader != "" && rangeStillValid(r, etag, lastModified) {
+		start, end, ok := parseByteRange(rangeHeader, size)
+		if !ok {
+			header.Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+			http.Error(w, "requested range not satisfiable", http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+		if start >= 0 {
+			offset, count, status = start, end-start+1, http.StatusPartialContent
+			header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, size))
+		}
+	}
+	header.Set("Content-Length", strconv.FormatInt(count, 10))
+
+	if r.Method == http.MethodHead || count == 0 {
+		w.WriteHeader(status)
+		return
+	}
+
+	// Download exactly the bytes being served, from the version of the blob the headers describe
+	get, err := blobURL.Download(ctx, offset, count, azblob.BlobAccessConditions{
+		ModifiedAccessConditions: azblob.ModifiedAccessConditions{IfMatch: props.ETag()},
+	}, false, azblob.ClientProvidedKeyOptions{})
+	if err != nil {
+		header.Del("Content-Range")
+		header.Del("Content-Length")
+		serveBlobError(w, err)
+		return
+	}
+	body := get.Body(azblob.RetryReaderOptions{MaxRetryRequests: 3})
+	defer body.Close()
+
+	w.WriteHeader(status)
+	io.Copy(w, body) // The status is already sent; a failure can only cut the response short
+}
+
+func serveBlobError(w http.ResponseWriter, err error) {
+	if isBlobNotFound(err) {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	http.Error(w, "storage error", http.StatusBadGateway)
+}
+
+// notModified evaluates If-None-Match, or If-Modified-Since when there is no If-None-Match, for a GET or HEAD.
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for _, candidate := range strings.Split(inm, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == "*" || candidate == etag {
+				return true
+			}
+		}
+		return false
+	}
+
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" {
+		if since, err := http.ParseTime(ims); err == nil {
+			return !lastModified.Truncate(time.Second).After(since)
+		}
+	}
+
+	return false
+}
+
+// rangeStillValid evaluates If-Range: the range only applies if the blob is still the version the client has.
+func rangeStillValid(r *http.Request, etag string, lastModified time.Time) bool {
+	ir := r.Header.Get("If-Range")
+	if ir == "" {
+		return true
+	}
+	if strings.HasPrefix(ir, `"`) {
+		return ir == etag
+	}
+	if date, err := http.ParseTime(ir); err == nil {
+		return lastModified.Truncate(time.Second).Equal(date)
+	}
+
+	return false
+}
+
+// parseByteRange parses a Range header against a blob of size bytes, returning the inclusive range to serve. A start
+// of -1 means the header is ignored (several ranges, or a unit other than bytes); ok is false when it can't be met.
+func parseByteRange(header string, size int64) (start, end int64, ok bool) {
+	spec := strings.TrimPrefix(header, "bytes=")
+	if spec == header || strings.Contains(spec, ",") {
+		return -1, -1, true
+	}
+
+	dash := strings.Index(spec, "-")
+	if dash < 0 {
+		return 0, 0, false
+	}
+	first, last := strings.TrimSpace(spec[:dash]), strings.TrimSpace(spec[dash+1:])
+
+	if first == "" {
+		// A suffix range: the last n bytes
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n <= 0 || size == 0 {
+			return 0, 0, false
+		}
+		if n > size {
+			n = size
+		}
+		return size - n, size - 1, true
+	}
+
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 || start >= size {
+		return 0, 0, false
+	}
+	end = size - 1
+	if last != "" {
+		end, err = strconv.ParseInt(last, 10, 64)
+		if err != nil || end < start {
+			return 0, 0, false
+		}
+		if end >= size {
+			end = size - 1
+		}
+	}
+
+	return start, end, true
+}
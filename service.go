@@ -2,145 +2,981 @@ package azurestorage
 
 import (
 	"bytes"
+	"compress/gzip"
+	"compress/zlib"
 	"context"
+	"crypto/md5"
+	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net/url"
+	"os"
 	"strings"
+	"time"
 
 	"github.com/Azure/azure-storage-blob-go/azblob"
 	"github.com/Azure/azure-storage-file-go/azfile"
-)
-
-var (
-	ctx = context.Background() // This example uses a never-expiring context.
+	"github.com/andybalholm/brotli"
 )
 
 // ================================================================================================================================================
 // Azure Storage - BLOB Functions
 // ================================================================================================================================================
 
-func GetBlobService(accountName *string, accountKey *string, blobServiceURL *string) (azblob.ServiceURL, error) {
+func GetBlobService(accountName *string, accountKey *string, blobServiceURL *string, options ...Option) (azblob.ServiceURL, error) {
+
+	// Use your Storage account's name and key to create a credential object; this is used to access your account.
+	credential, err := azblob.NewSharedKeyCredential(*accountName, *accountKey)
+	if err != nil {
+		return azblob.ServiceURL{}, err
+	}
+
+	// Create a request pipeline that is used to process HTTP(S) requests and responses. It requires
+	// your account credentials. In more advanced scenarios, you can configure telemetry, retry policies,
+	// logging, and other options. Also, you can configure multiple request pipelines for different scenarios.
+	p, err := newBlobPipeline(credential, options)
+	if err != nil {
+		return azblob.ServiceURL{}, err
+	}
+
+	// From the Azure portal, get your Storage account blob service URL endpoint.
+	// The URL typically looks like this:
+	u, _ := url.Parse(fmt.Sprintf(*blobServiceURL, *accountName))
+
+	// Create an ServiceURL object that wraps the service URL and a request pipeline.
+	return azblob.NewServiceURL(*u, p), nil
+}
+
+// GetBlobServiceWithSAS returns the blob service of the account that sasURL, a service URL carrying an account SAS
+// token, points at. No account key is involved: every request is authorised by the token, with its permissions and
+// expiry.
+func GetBlobServiceWithSAS(sasURL string, options ...Option) (azblob.ServiceURL, error) {
+	u, err := parseServiceSASURL(sasURL)
+	if err != nil {
+		return azblob.ServiceURL{}, err
+	}
+
+	// The SAS in the URL authorises the requests, so the pipeline carries no credential of its own
+	p, err := newBlobPipeline(azblob.NewAnonymousCredential(), options)
+	if err != nil {
+		return azblob.ServiceURL{}, err
+	}
+
+	return azblob.NewServiceURL(*u, p), nil
+}
+
+func GetBlobContainer(serviceURL azblob.ServiceURL, containerName *string) azblob.ContainerURL {
+	// Now, you can use the serviceURL to perform various container and blob operations.
+
+	// All HTTP operations allow you to specify a Go context.Context object to control cancellation/timeout.
+
+	// This example shows several common operations just to get you started.
+
+	// Create a URL that references a to-be-created container in your Azure Storage account.
+	// This returns a ContainerURL object that wraps the container's URL and a request pipeline (inherited from serviceURL)
+	return serviceURL.NewContainerURL(*containerName) // Container names require lowercase
+}
+
+// CreateBlobContainer creates the container with no metadata. access sets who may read it without credentials:
+// PublicAccessNone for no one, PublicAccessBlob for the blobs but not the listing, PublicAccessContainer for both.
+func CreateBlobContainer(ctx context.Context, containerURL azblob.ContainerURL, access azblob.PublicAccessType) error {
+	// Create the container on the service
+	_, err := containerURL.Create(ctx, azblob.Metadata{}, access)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// SetContainerAccess changes the public access level of the container, keeping its stored access policies. The
+// service only allows public access when the account permits it.
+func SetContainerAccess(ctx context.Context, containerURL azblob.ContainerURL, access azblob.PublicAccessType) error {
+	// Setting the access level replaces the stored access policies too, so send the current ones back
+	policies, err := containerURL.GetAccessPolicy(ctx, azblob.LeaseAccessConditions{})
+	if err != nil {
+		return err
+	}
+
+	// The service ignores ETag conditions here; the last-modified time guards against a concurrent policy change
+	_, err = containerURL.SetAccessPolicy(ctx, access, policies.Items, azblob.ContainerAccessConditions{
+		ModifiedAccessConditions: azblob.ModifiedAccessConditions{IfUnmodifiedSince: policies.LastModified()},
+	})
+	return err
+}
+
+func DeleteBlobContainer(ctx context.Context, containerURL azblob.ContainerURL) error {
+	// Delete the container we created earlier.
+	_, err := containerURL.Delete(ctx, azblob.ContainerAccessConditions{})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// UploadBlob uploads data as blobName in a single request. With computeMD5 the data is hashed first, costing an extra
+// pass over it, and the hash is sent along so the service rejects an upload corrupted in transit. To set other headers,
+// such as Cache-Control or Content-Disposition, use UploadBlobFull with UploadOptions.HTTPHeaders.
+func UploadBlob(ctx context.Context, containerURL azblob.ContainerURL, blobName *string, blobType *string, data io.ReadSeeker, computeMD5 bool) (azblob.BlockBlobURL, error) {
+	headers := azblob.BlobHTTPHeaders{ContentType: *blobType}
+	if computeMD5 {
+		sum, err := md5ReadSeeker(data)
+		if err != nil {
+			return azblob.BlockBlobURL{}, err
+		}
+		headers.ContentMD5 = sum
+	}
+
+	// Create a URL that references a to-be-created blob in your Azure Storage account's container.
+	// This returns a BlockBlobURL object that wraps the blob's URL and a request pipeline (inherited from containerURL)
+	blobURL := containerURL.NewBlockBlobURL(*blobName) // Blob names can be mixed case
+
+	// Upload the blob
+	_, err := blobURL.Upload(ctx, data, headers, azblob.Metadata{}, azblob.BlobAccessConditions{}, azblob.DefaultAccessTier, nil, azblob.ClientProvidedKeyOptions{})
+	if err != nil {
+		return azblob.BlockBlobURL{}, err
+	}
+
+	return blobURL, nil
+}
+
+// md5ReadSeeker hashes the rest of data and seeks back to where it started.
+func md5ReadSeeker(data io.ReadSeeker) ([]byte, error) {
+	start, err := data.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+
+	hash := md5.New()
+	if _, err := io.Copy(hash, data); err != nil {
+		return nil, err
+	}
+	if _, err := data.Seek(start, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	return hash.Sum(nil), nil
+}
+
+// offsetReadSeeker presents the rest of a stream, from the position it had when wrapped, as a stream of its own. The
+// SDK's uploads insist on a body positioned at 0, so this lets them send data that doesn't start at the beginning.
+type offsetReadSeeker struct {
+	io.ReadSeeker
+	base int64
+}
+
+func newOffsetReadSeeker(data io.ReadSeeker) (*offsetReadSeeker, error) {
+	base, err := data.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+
+	return &offsetReadSeeker{ReadSeeker: data, base: base}, nil
+}
+
+func (r *offsetReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	if whence == io.SeekStart {
+		offset += r.base
+	}
+
+	pos, err := r.ReadSeeker.Seek(offset, whence)
+	if err != nil {
+		return 0, err
+	}
+	if pos < r.base {
+		// Don't leave the stream before the part this presents
+		if _, err := r.ReadSeeker.Seek(r.base, io.SeekStart); err != nil {
+			return 0, err
+		}
+		return 0, errors.New("azurestorage: negative position")
+	}
+
+	return pos - r.base, nil
+}
+
+// UploadBlobFromFile uploads the file at filePath as blobName in blocks, so files of any size can be uploaded. The
+// content type comes from the file's extension, or from sniffing its first bytes when the extension is unknown.
+func UploadBlobFromFile(ctx context.Context, containerURL azblob.ContainerURL, blobName *string, filePath *string) (azblob.BlockBlobURL, error) {
+	file, err := os.Open(*filePath)
+	if err != nil {
+		return azblob.BlockBlobURL{}, err
+	}
+	defer file.Close()
+
+	// Detect the content type from the file's extension, falling back to sniffing its first bytes
+	peek := make([]byte, sniffLength)
+	n, err := io.ReadFull(file, peek)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return azblob.BlockBlobURL{}, err
+	}
+	contentType := DetectContentType(*filePath, peek[:n])
+
+	// Create a URL that references a to-be-created blob in your Azure Storage account's container.
+	blobURL := containerURL.NewBlockBlobURL(*blobName) // Blob names can be mixed case
+
+	// Upload the file in blocks so files of any size can be uploaded
+	_, err = azblob.UploadFileToBlockBlob(ctx, file, blobURL, azblob.UploadToBlockBlobOptions{
+		BlobHTTPHeaders: azblob.BlobHTTPHeaders{ContentType: contentType},
+	})
+	if err != nil {
+		return azblob.BlockBlobURL{}, err
+	}
+
+	return blobURL, nil
+}
+
+// StreamUpload uploads everything read from data as blobName, in blocks, with blobType as its content type. A stream
+// can't be hashed up front, so the MD5 of the content is computed as it is read and stored once the blob is committed,
+// for downloaders to verify; the hash is only stored if no one changed the blob in between. Use UploadLargeBlob to tune
+// the block size and memory use.
+func StreamUpload(ctx context.Context, containerURL azblob.ContainerURL, blobName *string, blobType *string, data io.Reader) (azblob.BlockBlobURL, error) {
+	// Create a URL that references a to-be-created blob in your Azure Storage account's container.
+	blobURL := containerURL.NewBlockBlobURL(*blobName) // Blob names can be mixed case
+
+	// Hash the content as it is read, since a stream can't be hashed up front. The blocks are read in order,
+	// so the hash matches the committed blob.
+	hash := md5.New()
+	uploadResp, err := azblob.UploadStreamToBlockBlob(ctx, io.TeeReader(data, hash), blobURL, azblob.UploadStreamToBlockBlobOptions{
+		BlobHTTPHeaders: azblob.BlobHTTPHeaders{ContentType: *blobType},
+	})
+	if err != nil {
+		return azblob.BlockBlobURL{}, err
+	}
+
+	// Store the hash so downloaders can verify the content. SetHTTPHeaders replaces all the headers, so the
+	// content type is sent again; the ETag condition makes sure the hash isn't stored on a blob that changed since.
+	_, err = blobURL.SetHTTPHeaders(ctx, azblob.BlobHTTPHeaders{ContentType: *blobType, ContentMD5: hash.Sum(nil)}, azblob.BlobAccessConditions{
+		ModifiedAccessConditions: azblob.ModifiedAccessConditions{IfMatch: uploadResp.ETag()},
+	})
+	if err != nil {
+		return azblob.BlockBlobURL{}, err
+	}
+
+	return blobURL, nil
+}
+
+// UploadLargeBlob uploads everything read from reader, of any length, without knowing the length up front. It reads
+// bufferSize bytes (4 MiB when 0) per block and keeps up to maxBuffers blocks (16 when 0) in flight, so memory use is
+// bounded by bufferSize*maxBuffers.
+func UploadLargeBlob(ctx context.Context, containerURL azblob.ContainerURL, blobName *string, reader io.Reader, bufferSize int, maxBuffers int) (azblob.BlockBlobURL, error) {
+	if bufferSize <= 0 {
+		bufferSize = 4 * 1024 * 1024
+	}
+	if maxBuffers <= 0 {
+		maxBuffers = defaultConcurrency
+	}
+
+	// Create a URL that references a to-be-created blob in your Azure Storage account's container.
+	blobURL := containerURL.NewBlockBlobURL(*blobName) // Blob names can be mixed case
+
+	_, err := azblob.UploadStreamToBlockBlob(ctx, reader, blobURL, azblob.UploadStreamToBlockBlobOptions{
+		BufferSize: bufferSize,
+		MaxBuffers: maxBuffers,
+	})
+	if err != nil {
+		return azblob.BlockBlobURL{}, err
+	}
+
+	return blobURL, nil
+}
+
+// UploadBlobIfChanged uploads data, from its current position, as blobName unless the blob already holds the same
+// content, and reports whether it uploaded. The content is compared by MD5 with the hash stored on the blob, which the
+// upload stores for the next comparison, so it costs a pass over data and a properties request but no download.
+func UploadBlobIfChanged(ctx context.Context, containerURL azblob.ContainerURL, blobName string, data io.ReadSeeker, contentType string) (bool, error) {
+	// Hash the content from where data is positioned, then rewind it to there for the upload
+	contentMD5, err := md5ReadSeeker(data)
+	if err != nil {
+		return false, err
+	}
+	body, err := newOffsetReadSeeker(data)
+	if err != nil {
+		return false, err
+	}
+
+	// Create a URL that references the blob in your Azure Storage account's container.
+	blobURL := containerURL.NewBlockBlobURL(blobName) // Blob names can be mixed case
+
+	// Skip the upload when the existing blob already holds the same content
+	props, err := blobURL.GetProperties(ctx, azblob.BlobAccessConditions{}, azblob.ClientProvidedKeyOptions{})
+	if err != nil && !isBlobNotFound(err) {
+		return false, err
+	}
+	if err == nil && bytes.Equal(props.ContentMD5(), contentMD5) {
+		return false, nil
+	}
+
+	// Upload the blob, storing the hash the next comparison is made against
+	_, err = blobURL.Upload(ctx, body, azblob.BlobHTTPHeaders{ContentType: contentType, ContentMD5: contentMD5}, azblob.Metadata{}, azblob.BlobAccessConditions{}, azblob.DefaultAccessTier, nil, azblob.ClientProvidedKeyOptions{})
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// IdempotencyKeyMetadata is the metadata key UploadBlobIdempotent stores the idempotency key under.
+const IdempotencyKeyMetadata = "idempotency_key"
+
+// UploadBlobIdempotent uploads data as blobName together with idempotencyKey, stored in the blob's metadata, for
+// consumers of at-least-once deliveries. It returns false without uploading when the blob already carries the key,
+// left there by an earlier delivery of the same operation; a blob with another key is replaced. The upload is
+// conditional on the blob being as checked, so two deliveries racing each other upload it only once. When another
+// writer changes the blob in between without leaving the key, nothing is written and ErrPreconditionFailed is returned.
+func UploadBlobIdempotent(ctx context.Context, containerURL azblob.ContainerURL, blobName string, data io.ReadSeeker, idempotencyKey string) (bool, error) {
+	// Create a URL that references the blob in your Azure Storage account's container.
+	blobURL := containerURL.NewBlockBlobURL(blobName) // Blob names can be mixed case
+
+	// A blob carrying the same key was uploaded by an earlier delivery of the same operation
+	props, err := blobURL.GetProperties(ctx, azblob.BlobAccessConditions{}, azblob.ClientProvidedKeyOptions{})
+	if err != nil && !isBlobNotFound(err) {
+		return false, err
+	}
+	exists := err == nil
+	if exists && props.NewMetadata()[IdempotencyKeyMetadata] == idempotencyKey {
+		return false, nil
+	}
+
+	// Upload the blob together with the key, so the key is only ever seen on a complete upload, provided no one wrote
+	// the blob since it was checked
+	conditions := azblob.ModifiedAccessConditions{IfNoneMatch: azblob.ETagAny}
+	if exists {
+		conditions = azblob.ModifiedAccessConditions{IfMatch: props.ETag()}
+	}
+	_, err = blobURL.Upload(ctx, data, azblob.BlobHTTPHeaders{}, azblob.Metadata{IdempotencyKeyMetadata: idempotencyKey}, azblob.BlobAccessConditions{
+		ModifiedAccessConditions: conditions,
+	}, azblob.DefaultAccessTier, nil, azblob.ClientProvidedKeyOptions{})
+
+	// A create-only upload of an existing blob is refused as a conflict rather than a failed precondition
+	var stgErr azblob.StorageError
+	if isConditionNotMet(err) || (errors.As(err, &stgErr) && stgErr.ServiceCode() == azblob.ServiceCodeBlobAlreadyExists) {
+		// Someone else wrote the blob first: a concurrent delivery of the same operation if it left the same key
+		props, err := blobURL.GetProperties(ctx, azblob.BlobAccessConditions{}, azblob.ClientProvidedKeyOptions{})
+		if err != nil && !isBlobNotFound(err) {
+			return false, err
+		}
+		if err == nil && props.NewMetadata()[IdempotencyKeyMetadata] == idempotencyKey {
+			return false, nil
+		}
+		return false, fmt.Errorf("%w: %s changed", ErrPreconditionFailed, blobName)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// UploadBlobConditional uploads data as blobName only if conditions hold, and returns the new ETag for the next
+// conditional write. Pass IfMatch with the ETag last read to update a blob no one changed since, or IfNoneMatch with
+// azblob.ETagAny to create a blob that doesn't exist yet. When the condition fails nothing is written and
+// ErrPreconditionFailed is returned.
+func UploadBlobConditional(ctx context.Context, containerURL azblob.ContainerURL, blobName string, data io.ReadSeeker, contentType string, conditions azblob.ModifiedAccessConditions) (azblob.ETag, error) {
+	blobURL := containerURL.NewBlockBlobURL(blobName) // Blob names can be mixed case
+
+	resp, err := blobURL.Upload(ctx, data, azblob.BlobHTTPHeaders{ContentType: contentType}, azblob.Metadata{}, azblob.BlobAccessConditions{
+		ModifiedAccessConditions: conditions,
+	}, azblob.DefaultAccessTier, nil, azblob.ClientProvidedKeyOptions{})
+	if err != nil {
+		// A create-only upload of an existing blob is refused as a conflict rather than a failed precondition
+		var stgErr azblob.StorageError
+		if isConditionNotMet(err) || (errors.As(err, &stgErr) && stgErr.ServiceCode() == azblob.ServiceCodeBlobAlreadyExists) {
+			return azblob.ETagNone, fmt.Errorf("%w: %s changed", ErrPreconditionFailed, blobName)
+		}
+		return azblob.ETagNone, err
+	}
+
+	return resp.ETag(), nil
+}
+
+// UploadOptions bundles everything UploadBlobFull sets on a new blob. ContentType, when set, takes precedence over
+// HTTPHeaders.ContentType; an empty Tier leaves the blob in the account's default tier. ComputeMD5 hashes the data
+// and sends the hash so the service rejects a corrupted upload, unless HTTPHeaders.ContentMD5 already holds one.
+type UploadOptions struct {
+	ContentType string
+	HTTPHeaders azblob.BlobHTTPHeaders
+	Metadata    azblob.Metadata
+	Tags        map[string]string
+	Tier        azblob.AccessTierType
+	ComputeMD5  bool
+}
+
+// UploadBlobFull uploads data as blobName in a single Put Blob request that also sets everything in opts: headers,
+// metadata, index tags and access tier. The blob is therefore never visible half-configured. The metadata and tags are
+// validated before anything is sent.
+func UploadBlobFull(ctx context.Context, containerURL azblob.ContainerURL, blobName string, data io.ReadSeeker, opts UploadOptions) (azblob.BlockBlobURL, error) {
+	if err := ValidateMetadataSize(opts.Metadata); err != nil {
+		return azblob.BlockBlobURL{}, err
+	}
+	if err := ValidateBlobTags(opts.Tags); err != nil {
+		return azblob.BlockBlobURL{}, err
+	}
+
+	headers := opts.HTTPHeaders
+	if opts.ContentType != "" {
+		headers.ContentType = opts.ContentType
+	}
+	if opts.ComputeMD5 && len(headers.ContentMD5) == 0 {
+		sum, err := md5ReadSeeker(data)
+		if err != nil {
+			return azblob.BlockBlobURL{}, err
+		}
+		headers.ContentMD5 = sum
+	}
+
+	// Create a URL that references a to-be-created blob in your Azure Storage account's container.
+	blobURL := containerURL.NewBlockBlobURL(blobName) // Blob names can be mixed case
+
+	// Put Blob takes the headers, metadata, tags and tier together, so the whole blob is written in one request
+	// and is never visible half-configured.
+	_, err := blobURL.Upload(ctx, data, headers, opts.Metadata, azblob.BlobAccessConditions{}, opts.Tier, azblob.BlobTagsMap(opts.Tags), azblob.ClientProvidedKeyOptions{})
+	if err != nil {
+		return azblob.BlockBlobURL{}, err
+	}
+
+	return blobURL, nil
+}
+
+func DownloadBlob(ctx context.Context, containerURL azblob.ContainerURL, blobName *string) (*azblob.DownloadResponse, error) {
+	// Create a URL that references a to-be-created blob in your Azure Storage account's container.
+	// This returns a BlockBlobURL object that wraps the blob's URL and a request pipeline (inherited from containerURL)
+	blobURL := containerURL.NewBlockBlobURL(*blobName) // Blob names can be mixed case
+
+	// Download the blob's contents and verify that it worked correctly
+	return blobURL.Download(ctx, 0, 0, azblob.BlobAccessConditions{}, false, azblob.ClientProvidedKeyOptions{})
+}
+
+// DownloadBlobRange downloads count bytes of blobName starting at offset, as DownloadBlobURLRange does for a blob URL.
+// The caller must close the body of the response.
+func DownloadBlobRange(ctx context.Context, containerURL azblob.ContainerURL, blobName string, offset, count int64) (*azblob.DownloadResponse, error) {
+	// Create a URL that references the blob in your Azure Storage account's container.
+	blobURL := containerURL.NewBlockBlobURL(blobName) // Blob names can be mixed case
+
+	return DownloadBlobURLRange(ctx, blobURL, offset, count)
+}
+
+// DownloadBlobURLRange downloads count bytes of blobURL starting at offset, like DownloadBlobRange, for callers that
+// already hold the blob's URL, such as a proxy serving HTTP range requests or a download resuming where it stopped.
+// count is positive, or CountToEnd (or azblob's 0) to read to the end; a negative offset or count returns
+// ErrInvalidRange without sending a request.
+func DownloadBlobURLRange(ctx context.Context, blobURL azblob.BlockBlobURL, offset, count int64) (*azblob.DownloadResponse, error) {
+	// Accept CountToEnd as well as azblob's own 0 for "to the end", and reject negative offsets or counts
+	count, err := blobRangeCount(offset, count)
+	if err != nil {
+		return nil, err
+	}
+
+	// Download count bytes of the blob's contents, starting at offset
+	return blobURL.Download(ctx, offset, count, azblob.BlobAccessConditions{}, false, azblob.ClientProvidedKeyOptions{})
+}
+
+// DownloadBlobDecoded writes the content of blobName to w with its Content-Encoding undone, for blobs stored compressed
+// with gzip, deflate or br (or several of them, in the order listed). A blob whose encoding can't be undone fails
+// before anything is written, rather than be written still encoded.
+func DownloadBlobDecoded(ctx context.Context, containerURL azblob.ContainerURL, blobName string, w io.Writer) error {
+	// Create a URL that references the blob in your Azure Storage account's container.
+	blobURL := containerURL.NewBlobURL(blobName) // Blob names can be mixed case
+
+	// Download the blob; the response carries its Content-Encoding so no separate properties call is needed
+	get, err := blobURL.Download(ctx, 0, azblob.CountToEnd, azblob.BlobAccessConditions{}, false, azblob.ClientProvidedKeyOptions{})
+	if err != nil {
+		return err
+	}
+
+	body := get.Body(azblob.RetryReaderOptions{})
+	decoded, err := newContentDecoder(get.ContentEncoding(), body)
+	if err != nil {
+		body.Close()
+		return err
+	}
+	defer decoded.Close() // Closes the decoders and the response body, which the client must close when finished with it
+
+	_, err = io.Copy(w, decoded)
+	return err
+}
+
+// decodingReadCloser reads the decoded content and closes every decoder, outermost first, and then the body.
+type decodingReadCloser struct {
+	io.Reader
+	decoders []io.Closer // In the order they were opened, each reading from the one before
+	body     io.Closer
+}
+
+func (d *decodingReadCloser) Close() error {
+	err := d.closeDecoders()
+	if bodyErr := d.body.Close(); err == nil {
+		err = bodyErr
+	}
+
+	return err
+}
+
+func (d *decodingReadCloser) closeDecoders() error {
+	var firstErr error
+	for i := len(d.decoders) - 1; i >= 0; i-- {
+		if err := d.decoders[i].Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
+
+// newContentDecoder undoes contentEncoding on body. Closing the result closes body too; on error the decoders
+// opened so far are closed, but body is left to the caller.
+func newContentDecoder(contentEncoding string, body io.ReadCloser) (io.ReadCloser, error) {
+	d := &decodingReadCloser{Reader: body, body: body}
+
+	// Encodings are listed in the order they were applied, so they are undone from last to first.
+	// An unknown encoding can't be undone, so it fails the download rather than return partly decoded bytes.
+	encodings := strings.Split(contentEncoding, ",")
+	for i := len(encodings) - 1; i >= 0; i-- {
+		var decoder io.ReadCloser
+		var err error
+		switch encoding := strings.ToLower(strings.TrimSpace(encodings[i])); encoding {
+		case "gzip", "x-gzip":
+			decoder, err = gzip.NewReader(d.Reader)
+		case "deflate":
+			decoder, err = zlib.NewReader(d.Reader)
+		case "br":
+			d.Reader = brotli.NewReader(d.Reader) // Holds nothing that needs closing
+			continue
+		case "", "identity":
+			continue // Nothing to undo
+		default:
+			err = fmt.Errorf("azurestorage: unsupported content encoding %q", encoding)
+		}
+		if err != nil {
+			d.closeDecoders()
+			return nil, err
+		}
+
+		d.Reader = decoder
+		d.decoders = append(d.decoders, decoder)
+	}
+
+	return d, nil
+}
+
+// DeleteBlob deletes blobName. snapshots chooses what happens to its snapshots: DeleteSnapshotsOptionInclude deletes
+// them along with the blob, DeleteSnapshotsOptionOnly deletes only them, and DeleteSnapshotsOptionNone fails for a
+// blob that has any.
+func DeleteBlob(ctx context.Context, containerURL azblob.ContainerURL, blobName *string, snapshots azblob.DeleteSnapshotsOptionType) error {
+	// Create a URL that references a to-be-created blob in your Azure Storage account's container.
+	// This returns a BlockBlobURL object that wraps the blob's URL and a request pipeline (inherited from containerURL)
+	blobURL := containerURL.NewBlockBlobURL(*blobName) // Blob names can be mixed case
+
+	// Delete the blob
+	_, err := blobURL.Delete(ctx, snapshots, azblob.BlobAccessConditions{})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// UndeleteBlob restores a soft-deleted blob, along with its soft-deleted snapshots, while it is still within the
+// account's retention period.
+func UndeleteBlob(ctx context.Context, blobURL azblob.BlockBlobURL) error {
+	_, err := blobURL.Undelete(ctx)
+	return err
+}
+
+// SnapshotBlob takes a read-only snapshot of the blob's current content and metadata and returns its timestamp. Read
+// the snapshot back with blobURL.WithSnapshot(timestamp).
+func SnapshotBlob(ctx context.Context, blobURL azblob.BlockBlobURL) (string, error) {
+	resp, err := blobURL.CreateSnapshot(ctx, azblob.Metadata{}, azblob.BlobAccessConditions{}, azblob.ClientProvidedKeyOptions{})
+	if err != nil {
+		return "", err
+	}
+
+	return resp.Snapshot(), nil
+}
+
+// ListBlobSnapshots returns the timestamps of the snapshots of blobName, oldest first.
+func ListBlobSnapshots(ctx context.Context, containerURL azblob.ContainerURL, blobName *string) ([]string, error) {
+	var snapshots []string
+
+	// List the blobs sharing the name as a prefix, snapshots included, and keep the snapshots of the blob itself
+	for marker := (azblob.Marker{}); marker.NotDone(); { // The parens around Marker{} are required to avoid compiler error.
+		listBlob, err := containerURL.ListBlobsFlatSegment(ctx, marker, azblob.ListBlobsSegmentOptions{
+			Prefix:  *blobName,
+			Details: azblob.BlobListingDetails{Snapshots: true},
+		})
+		if err != nil {
+			return nil, err
+		}
+		marker = listBlob.NextMarker
+
+		for _, item := range listBlob.Segment.BlobItems {
+			if item.Name == *blobName && item.Snapshot != "" {
+				snapshots = append(snapshots, item.Snapshot)
+			}
+		}
+	}
+
+	return snapshots, nil
+}
+
+// GetListBlob lists every blob in the container, one slice per segment. With includeDeleted, soft-deleted blobs are
+// listed too, with Deleted set and their Properties.DeletedTime and Properties.RemainingRetentionDays filled in.
+func GetListBlob(ctx context.Context, containerURL azblob.ContainerURL, includeDeleted bool) ([][]azblob.BlobItemInternal, error) {
+	var results [][]azblob.BlobItemInternal
+	options := azblob.ListBlobsSegmentOptions{Details: azblob.BlobListingDetails{Deleted: includeDeleted}}
+
+	// List the blob(s) in our container; since a container may hold millions of blobs, this is done 1 segment at a time.
+	for marker := (azblob.Marker{}); marker.NotDone(); { // The parens around Marker{} are required to avoid compiler error.
+		items, next, err := ListBlobsPage(ctx, containerURL, marker, options)
+		if err != nil {
+			return nil, err
+		}
+		marker = next
+
+		// Process the blobs returned in this result segment (if the segment is empty, the loop body won't execute)
+		for _, blobInfo := range items {
+			fmt.Print("Blob name: " + blobInfo.Name + "\n")
+		}
+
+		results = append(results, items)
+	}
+
+	return results, nil
+}
+
+// ListBlobsPage lists the one segment of blobs starting at marker and returns it with the marker of the next segment,
+// so callers can page through a container of any size at their own pace. Start with an empty Marker and stop when
+// next.NotDone() is false.
+func ListBlobsPage(ctx context.Context, containerURL azblob.ContainerURL, marker azblob.Marker, opts azblob.ListBlobsSegmentOptions) (items []azblob.BlobItemInternal, next azblob.Marker, err error) {
+	// Get a result segment starting with the blob indicated by the current Marker.
+	listBlob, err := containerURL.ListBlobsFlatSegment(ctx, marker, opts)
+	if err != nil {
+		return nil, azblob.Marker{}, err
+	}
+
+	// IMPORTANT: ListBlobs returns the start of the next segment; you MUST use this to get
+	// the next segment (after processing the current result segment).
+	return listBlob.Segment.BlobItems, listBlob.NextMarker, nil
+}
+
+// ListBlobsByPrefix lists the blobs whose names start with prefix, one slice per segment, stopping after maxResults
+// blobs; a maxResults of 0 lists them all.
+func ListBlobsByPrefix(ctx context.Context, containerURL azblob.ContainerURL, prefix string, maxResults int) ([][]azblob.BlobItemInternal, error) {
+	var results [][]azblob.BlobItemInternal
+
+	for marker, remaining := (azblob.Marker{}), maxResults; marker.NotDone(); { // The parens around Marker{} are required to avoid compiler error.
+		// Don't ask for more than are still wanted; the service returns at most 5000 per segment either way
+		options := azblob.ListBlobsSegmentOptions{Prefix: prefix}
+		if maxResults > 0 && remaining < 5000 {
+			options.MaxResults = int32(remaining)
+		}
+
+		listBlob, err := containerURL.ListBlobsFlatSegment(ctx, marker, options)
+		if err != nil {
+			return nil, err
+		}
+		marker = listBlob.NextMarker
+
+		results = append(results, listBlob.Segment.BlobItems)
+		if maxResults > 0 {
+			remaining -= len(listBlob.Segment.BlobItems)
+			if remaining <= 0 {
+				break
+			}
+		}
+	}
+
+	return results, nil
+}
+
+// ListBlobsHierarchy lists one level of the virtual folder tree under prefix: prefixes holds the folders (each ending
+// with delimiter, "/" when empty) and blobs the blobs directly at that level.
+func ListBlobsHierarchy(ctx context.Context, containerURL azblob.ContainerURL, prefix, delimiter string) (prefixes []string, blobs []azblob.BlobItemInternal, err error) {
+	if delimiter == "" {
+		delimiter = "/"
+	}
+
+	for marker := (azblob.Marker{}); marker.NotDone(); { // The parens around Marker{} are required to avoid compiler error.
+		listBlob, err := containerURL.ListBlobsHierarchySegment(ctx, marker, delimiter, azblob.ListBlobsSegmentOptions{Prefix: prefix})
+		if err != nil {
+			return nil, nil, err
+		}
+		marker = listBlob.NextMarker
+
+		for _, blobPrefix := range listBlob.Segment.BlobPrefixes {
+			prefixes = append(prefixes, blobPrefix.Name)
+		}
+		blobs = append(blobs, listBlob.Segment.BlobItems...)
+	}
+
+	return prefixes, blobs, nil
+}
+
+// ListBlobsDetailed returns every blob under prefix, with the details selected by details (metadata, tags, snapshots,
+// versions, deleted blobs, uncommitted blobs, copy status) fetched in the same listing, reading all of its pages.
+func ListBlobsDetailed(ctx context.Context, containerURL azblob.ContainerURL, prefix string, details azblob.BlobListingDetails) ([]azblob.BlobItemInternal, error) {
+	var results []azblob.BlobItemInternal
+
+	// List the blob(s) under prefix with every requested detail (metadata, tags, snapshots, versions, deleted blobs...)
+	// in the same pass, 1 segment at a time.
+	for marker := (azblob.Marker{}); marker.NotDone(); { // The parens around Marker{} are required to avoid compiler error.
+		listBlob, err := containerURL.ListBlobsFlatSegment(ctx, marker, azblob.ListBlobsSegmentOptions{Details: details, Prefix: prefix})
+		if err != nil {
+			return nil, err
+		}
+		marker = listBlob.NextMarker
+
+		results = append(results, listBlob.Segment.BlobItems...)
+	}
+
+	return results, nil
+}
+
+// BlobProperties holds the commonly used system properties of a blob without depending on the SDK response type.
+type BlobProperties struct {
+	ContentLength int64
+	ContentType   string
+	LastModified  time.Time
+	ETag          azblob.ETag
+	AccessTier    azblob.AccessTierType
+
+	// Details of the last copy in which this blob was the destination; empty when the blob was never copied.
+	CopySource         string
+	CopyID             string
+	CopyStatus         azblob.CopyStatusType
+	CopyCompletionTime time.Time
+}
+
+// StatBlob returns the properties of blobName, its size, content type, ETag, tier and last copy among them, without
+// downloading its content.
+func StatBlob(ctx context.Context, containerURL azblob.ContainerURL, blobName *string) (BlobProperties, error) {
+	// Create a URL that references the blob in your Azure Storage account's container.
+	blobURL := containerURL.NewBlockBlobURL(*blobName) // Blob names can be mixed case
+
+	// Read the blob's properties without downloading its content
+	props, err := GetBlobProperties(ctx, blobURL)
+	if err != nil {
+		return BlobProperties{}, err
+	}
+
+	return NewBlobProperties(props), nil
+}
+
+// GetBlobProperties returns the full set of properties of the blob, without downloading its content. Use StatBlob, or
+// NewBlobProperties on the result, for the commonly used ones without depending on the SDK response type.
+func GetBlobProperties(ctx context.Context, blobURL azblob.BlockBlobURL) (*azblob.BlobGetPropertiesResponse, error) {
+	return blobURL.GetProperties(ctx, azblob.BlobAccessConditions{}, azblob.ClientProvidedKeyOptions{})
+}
+
+// SetBlobHTTPHeaders updates the HTTP headers the blob is served with, such as Cache-Control and Content-Disposition,
+// without uploading it again. Fields left empty in headers keep their current value, so setting only CacheControl
+// keeps the content type; the service would otherwise clear every header not sent. When the blob changes between
+// reading and updating its headers nothing is written and ErrPreconditionFailed is returned.
+func SetBlobHTTPHeaders(ctx context.Context, blobURL azblob.BlockBlobURL, headers azblob.BlobHTTPHeaders) error {
+	props, err := blobURL.GetProperties(ctx, azblob.BlobAccessConditions{}, azblob.ClientProvidedKeyOptions{})
+	if err != nil {
+		return err
+	}
+
+	merged := props.NewHTTPHeaders()
+	if headers.ContentType != "" {
+		merged.ContentType = headers.ContentType
+	}
+	if headers.ContentEncoding != "" {
+		merged.ContentEncoding = headers.ContentEncoding
+	}
+	if headers.ContentLanguage != "" {
+		merged.ContentLanguage = headers.ContentLanguage
+	}
+	if headers.ContentDisposition != "" {
+		merged.ContentDisposition = headers.ContentDisposition
+	}
+	if headers.CacheControl != "" {
+		merged.CacheControl = headers.CacheControl
+	}
+	if len(headers.ContentMD5) > 0 {
+		merged.ContentMD5 = headers.ContentMD5
+	}
 
-	// Use your Storage account's name and key to create a credential object; this is used to access your account.
-	credential, err := azblob.NewSharedKeyCredential(*accountName, *accountKey)
-	if err != nil {
-		return azblob.ServiceURL{}, err
+	_, err = blobURL.SetHTTPHeaders(ctx, merged, azblob.BlobAccessConditions{
+		ModifiedAccessConditions: azblob.ModifiedAccessConditions{IfMatch: props.ETag()},
+	})
+	if isConditionNotMet(err) {
+		return fmt.Errorf("%w: %s changed", ErrPreconditionFailed, azblob.NewBlobURLParts(blobURL.URL()).BlobName)
 	}
 
-	// Create a request pipeline that is used to process HTTP(S) requests and responses. It requires
-	// your account credentials. In more advanced scenarios, you can configure telemetry, retry policies,
-	// logging, and other options. Also, you can configure multiple request pipelines for different scenarios.
-	p := azblob.NewPipeline(credential, azblob.PipelineOptions{})
+	return err
+}
 
-	// From the Azure portal, get your Storage account blob service URL endpoint.
-	// The URL typically looks like this:
-	u, _ := url.Parse(fmt.Sprintf(*blobServiceURL, *accountName))
+// NewBlobProperties picks the commonly used properties out of an SDK properties response.
+func NewBlobProperties(props *azblob.BlobGetPropertiesResponse) BlobProperties {
+	return BlobProperties{
+		ContentLength:      props.ContentLength(),
+		ContentType:        props.ContentType(),
+		LastModified:       props.LastModified(),
+		ETag:               props.ETag(),
+		AccessTier:         azblob.AccessTierType(props.AccessTier()),
+		CopySource:         props.CopySource(),
+		CopyID:             props.CopyID(),
+		CopyStatus:         props.CopyStatus(),
+		CopyCompletionTime: props.CopyCompletionTime(),
+	}
+}
 
-	// Create an ServiceURL object that wraps the service URL and a request pipeline.
-	return azblob.NewServiceURL(*u, p), nil
+// FolderSizes returns the total size of the blobs in each virtual folder directly under prefix, keyed by the folder
+// name ending with delimiter ("/" when empty). A blob in a nested folder counts towards its top folder under prefix; the
+// blobs directly under prefix are summed under prefix itself.
+func FolderSizes(ctx context.Context, containerURL azblob.ContainerURL, prefix, delimiter string) (map[string]int64, error) {
+	return folderSizes(ctx, containerURL, prefix, delimiter, false)
 }
 
-func GetBlobContainer(serviceURL azblob.ServiceURL, containerName *string) azblob.ContainerURL {
-	// Now, you can use the serviceURL to perform various container and blob operations.
+// FolderSizesRecursive is like FolderSizes, but returns every folder at any depth below prefix, each blob counting
+// towards all of the folders it is nested in.
+func FolderSizesRecursive(ctx context.Context, containerURL azblob.ContainerURL, prefix, delimiter string) (map[string]int64, error) {
+	return folderSizes(ctx, containerURL, prefix, delimiter, true)
+}
 
-	// All HTTP operations allow you to specify a Go context.Context object to control cancellation/timeout.
+func folderSizes(ctx context.Context, containerURL azblob.ContainerURL, prefix, delimiter string, recursive bool) (map[string]int64, error) {
+	if delimiter == "" {
+		delimiter = "/"
+	}
 
-	// This example shows several common operations just to get you started.
+	// Sizes are keyed by virtual folder (ending with the delimiter); blobs directly under prefix are summed under prefix itself.
+	sizes := map[string]int64{}
 
-	// Create a URL that references a to-be-created container in your Azure Storage account.
-	// This returns a ContainerURL object that wraps the container's URL and a request pipeline (inherited from serviceURL)
-	return serviceURL.NewContainerURL(*containerName) // Container names require lowercase
-}
+	// List every blob under the prefix; a flat listing returns the nested blobs a hierarchical listing would hide behind a BlobPrefix.
+	for marker := (azblob.Marker{}); marker.NotDone(); { // The parens around Marker{} are required to avoid compiler error.
+		listBlob, err := containerURL.ListBlobsFlatSegment(ctx, marker, azblob.ListBlobsSegmentOptions{Prefix: prefix})
+		if err != nil {
+			return nil, err
+		}
+		marker = listBlob.NextMarker
 
-func CreateBlobContainer(containerURL azblob.ContainerURL) error {
-	// Create the container on the service (with no metadata and no public access)
-	_, err := containerURL.Create(ctx, azblob.Metadata{}, azblob.PublicAccessNone)
-	if err != nil {
-		return err
-	}
+		for _, blobInfo := range listBlob.Segment.BlobItems {
+			var size int64
+			if blobInfo.Properties.ContentLength != nil {
+				size = *blobInfo.Properties.ContentLength
+			}
 
-	return nil
-}
+			// Walk the folders between the prefix and the blob; without recursion only the first one is counted.
+			rest := strings.TrimPrefix(blobInfo.Name, prefix)
+			folder := prefix
+			counted := false
+			for {
+				i := strings.Index(rest, delimiter)
+				if i < 0 {
+					break
+				}
+				folder += rest[:i+len(delimiter)]
+				rest = rest[i+len(delimiter):]
 
-func DeleteBlobContainer(containerURL azblob.ContainerURL) error {
-	// Delete the container we created earlier.
-	_, err := containerURL.Delete(ctx, azblob.ContainerAccessConditions{})
-	if err != nil {
-		return err
+				sizes[folder] += size
+				counted = true
+				if !recursive {
+					break
+				}
+			}
+
+			if !counted {
+				sizes[prefix] += size
+			}
+		}
 	}
 
-	return nil
+	return sizes, nil
 }
 
-func UploadBlob(containerURL azblob.ContainerURL, blobName *string, blobType *string, data io.ReadSeeker) (azblob.BlockBlobURL, error) {
-	// Create a URL that references a to-be-created blob in your Azure Storage account's container.
-	// This returns a BlockBlobURL object that wraps the blob's URL and a request pipeline (inherited from containerURL)
-	blobURL := containerURL.NewBlockBlobURL(*blobName) // Blob names can be mixed case
+// RestoreBlobVersion makes the previous version versionID of blobName its current content again, by copying the
+// version over the blob, and returns once the copy completes. With versioning enabled, the content it replaces is
+// kept as a version in turn.
+func RestoreBlobVersion(ctx context.Context, containerURL azblob.ContainerURL, blobName, versionID string) error {
+	// Create URLs that reference the current blob and the previous version of it to promote.
+	blobURL := containerURL.NewBlobURL(blobName)
+	versionURL := blobURL.WithVersionID(versionID)
 
-	// Upload the blob
-	_, err := blobURL.Upload(ctx, data, azblob.BlobHTTPHeaders{ContentType: *blobType}, azblob.Metadata{}, azblob.BlobAccessConditions{}, azblob.DefaultAccessTier, nil, azblob.ClientProvidedKeyOptions{})
+	// Copy the version over the current blob. A copy within the same account is authorized by the account's own
+	// credential, so the asynchronous copy is used; it usually completes immediately for versions of the same blob.
+	copyResp, err := blobURL.StartCopyFromURL(ctx, versionURL.URL(), azblob.Metadata{}, azblob.ModifiedAccessConditions{}, azblob.BlobAccessConditions{}, azblob.DefaultAccessTier, nil)
 	if err != nil {
-		return azblob.BlockBlobURL{}, err
+		return err
 	}
 
-	return blobURL, nil
+	return waitForBlobCopy(ctx, blobURL, copyResp.CopyID(), copyResp.CopyStatus(), DefaultCopyBackoff)
 }
 
-func DownloadBlob(containerURL azblob.ContainerURL, blobName *string) (*azblob.DownloadResponse, error) {
-	// Create a URL that references a to-be-created blob in your Azure Storage account's container.
-	// This returns a BlockBlobURL object that wraps the blob's URL and a request pipeline (inherited from containerURL)
-	blobURL := containerURL.NewBlockBlobURL(*blobName) // Blob names can be mixed case
+// LatestOnly filters a listing that includes snapshots or versions down to the current blob of every name, keeping the
+// listing order.
+func LatestOnly(items []azblob.BlobItemInternal) []azblob.BlobItemInternal {
+	// Keep only the current blob of every name, dropping its snapshots and previous versions
+	var latest []azblob.BlobItemInternal
+	for _, item := range items {
+		if item.Snapshot != "" {
+			continue
+		}
+		if item.VersionID != nil && item.IsCurrentVersion != nil && !*item.IsCurrentVersion {
+			continue
+		}
+		latest = append(latest, item)
+	}
 
-	// Download the blob's contents and verify that it worked correctly
-	return blobURL.Download(ctx, 0, 0, azblob.BlobAccessConditions{}, false, azblob.ClientProvidedKeyOptions{})
+	return latest
 }
 
-func DeleteBlob(containerURL azblob.ContainerURL, blobName *string) error {
-	// Create a URL that references a to-be-created blob in your Azure Storage account's container.
-	// This returns a BlockBlobURL object that wraps the blob's URL and a request pipeline (inherited from containerURL)
-	blobURL := containerURL.NewBlockBlobURL(*blobName) // Blob names can be mixed case
-
-	// Delete the blob
-	_, err := blobURL.Delete(ctx, azblob.DeleteSnapshotsOptionNone, azblob.BlobAccessConditions{})
-	if err != nil {
-		return err
+// GroupByName groups a listing that includes snapshots or versions by blob name, each group holding the base blob, its
+// snapshots and its versions in the listing order.
+func GroupByName(items []azblob.BlobItemInternal) map[string][]azblob.BlobItemInternal {
+	// Group the base blob, its snapshots and its versions under the blob's name, keeping the listing order
+	groups := map[string][]azblob.BlobItemInternal{}
+	for _, item := range items {
+		groups[item.Name] = append(groups[item.Name], item)
 	}
 
-	return nil
+	return groups
 }
 
-func GetListBlob(containerURL azblob.ContainerURL) ([][]azblob.BlobItemInternal, error) {
-	var results [][]azblob.BlobItemInternal
+// OnlyCommittedBlobs drops from items the empty block blobs that only have uncommitted blocks, as they are uploads
+// still in progress. Through a service created with WithPartitionPrefix, items must be listed with metadata so the
+// block lists are read under the blobs' logical names.
+func OnlyCommittedBlobs(ctx context.Context, containerURL azblob.ContainerURL, items []azblob.BlobItemInternal) ([]azblob.BlobItemInternal, error) {
+	// Only an empty block blob is ambiguous: it is either truly empty or an upload whose blocks aren't committed yet
+	uncommitted := make([]bool, len(items))
+	var candidates []int
+	for i, item := range items {
+		if item.Properties.BlobType == azblob.BlobBlockBlob && (item.Properties.ContentLength == nil || *item.Properties.ContentLength == 0) {
+			candidates = append(candidates, i)
+		}
+	}
 
-	// List the blob(s) in our container; since a container may hold millions of blobs, this is done 1 segment at a time.
-	for marker := (azblob.Marker{}); marker.NotDone(); { // The parens around Marker{} are required to avoid compiler error.
-		// Get a result segment starting with the blob indicated by the current Marker.
-		listBlob, err := containerURL.ListBlobsFlatSegment(ctx, marker, azblob.ListBlobsSegmentOptions{})
+	// Get the block list of the ambiguous blobs; one with uncommitted blocks only is still being uploaded
+	errs := make([]error, len(candidates))
+	runConcurrently(len(candidates), defaultConcurrency, func(i int) {
+		item := items[candidates[i]]
+		blockList, err := containerURL.NewBlockBlobURL(LogicalBlobName(item)).GetBlockList(ctx, azblob.BlockListAll, azblob.LeaseAccessConditions{})
 		if err != nil {
-			return nil, err
+			errs[i] = fmt.Errorf("%s: %w", item.Name, err)
+			return
 		}
-		// IMPORTANT: ListBlobs returns the start of the next segment; you MUST use this to get
-		// the next segment (after processing the current result segment).
-		marker = listBlob.NextMarker
+		uncommitted[candidates[i]] = len(blockList.CommittedBlocks) == 0 && len(blockList.UncommittedBlocks) > 0
+	})
+	if failed := compactErrors(errs); len(failed) > 0 {
+		return nil, failed[0]
+	}
 
-		// Process the blobs returned in this result segment (if the segment is empty, the loop body won't execute)
-		for _, blobInfo := range listBlob.Segment.BlobItems {
-			fmt.Print("Blob name: " + blobInfo.Name + "\n")
+	var committed []azblob.BlobItemInternal
+	for i, item := range items {
+		if !uncommitted[i] {
+			committed = append(committed, item)
 		}
-
-		results = append(results, listBlob.Segment.BlobItems)
 	}
 
-	return results, nil
+	return committed, nil
 }
 
 // ================================================================================================================================================
 // Azure Storage - File Functions
 // ================================================================================================================================================
 
-func GetFileService(accountName *string, accountKey *string, fileServiceURL *string) (azfile.ServiceURL, error) {
+func GetFileService(accountName *string, accountKey *string, fileServiceURL *string, options ...Option) (azfile.ServiceURL, error) {
 	// Use your Storage account's name and key to create a credential object; this is used to access your account.
 	credential, err := azfile.NewSharedKeyCredential(*accountName, *accountKey)
 	if err != nil {
@@ -150,16 +986,53 @@ func GetFileService(accountName *string, accountKey *string, fileServiceURL *str
 	// Create a request pipeline that is used to process HTTP(S) requests and responses. It requires
 	// your account credentials. In more advanced scenarios, you can configure telemetry, retry policies,
 	// logging, and other options. Also, you can configure multiple request pipelines for different scenarios.
-	p := azfile.NewPipeline(credential, azfile.PipelineOptions{})
+	p, err := newFilePipeline(credential, options)
+	if err != nil {
+		return azfile.ServiceURL{}, err
+	}
 
 	// From the Azure portal, get your Storage account file service URL endpoint.
 	// The URL typically looks like this:
-	u, _ := url.Parse(fmt.Sprintf(*fileServiceURL, accountName))
+	u, _ := url.Parse(fmt.Sprintf(*fileServiceURL, *accountName))
 
 	// Create an ServiceURL object that wraps the service URL and a request pipeline.
 	return azfile.NewServiceURL(*u, p), nil
 }
 
+// GetFileServiceWithSAS returns the file service of the account that sasURL, a service URL carrying an account SAS
+// token, points at, like GetBlobServiceWithSAS.
+func GetFileServiceWithSAS(sasURL string, options ...Option) (azfile.ServiceURL, error) {
+	u, err := parseServiceSASURL(sasURL)
+	if err != nil {
+		return azfile.ServiceURL{}, err
+	}
+
+	// The SAS in the URL authorises the requests, so the pipeline carries no credential of its own
+	p, err := newFilePipeline(azfile.NewAnonymousCredential(), options)
+	if err != nil {
+		return azfile.ServiceURL{}, err
+	}
+
+	return azfile.NewServiceURL(*u, p), nil
+}
+
+// parseServiceSASURL parses a service URL with a SAS token, including the IP-style URLs of the storage emulator. URLs
+// of a container, share, blob or file are rejected, as the package derives every other URL from the service's.
+func parseServiceSASURL(sasURL string) (*url.URL, error) {
+	u, err := url.Parse(sasURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Query().Get("sig") == "" {
+		return nil, errors.New("azurestorage: URL has no SAS token")
+	}
+	if parts := azblob.NewBlobURLParts(*u); parts.ContainerName != "" { // Blob and file URLs share the same layout
+		return nil, fmt.Errorf("azurestorage: %s is not a service URL; pass the account's endpoint with an account SAS", u.Path)
+	}
+
+	return u, nil
+}
+
 func GetFileShare(serviceURL azfile.ServiceURL, shareName *string) azfile.ShareURL {
 	// This example shows several common operations just to get you started.
 
@@ -168,41 +1041,164 @@ func GetFileShare(serviceURL azfile.ServiceURL, shareName *string) azfile.ShareU
 	return serviceURL.NewShareURL(*shareName) // Share names require lowercase
 }
 
-func CreateFileShare(shareURL azfile.ShareURL) error {
-	// Create the share on the service (with no metadata and default quota size)
-	_, err := shareURL.Create(ctx, azfile.Metadata{}, 0)
-	if err != nil && err.(azfile.StorageError) != nil && err.(azfile.StorageError).ServiceCode() != azfile.ServiceCodeShareAlreadyExists {
-		return err
+func CreateFileShare(ctx context.Context, shareURL azfile.ShareURL, quotaGiB int32) error {
+	// Create the share on the service (with no metadata); a quota of 0 uses the service's default size.
+	// On a premium account the quota is the share's provisioned size, which determines its baseline IOPS and throughput.
+	_, err := shareURL.Create(ctx, azfile.Metadata{}, quotaGiB)
+	var stgErr azfile.StorageError
+	if errors.As(err, &stgErr) && stgErr.ServiceCode() == azfile.ServiceCodeShareAlreadyExists {
+		return nil // The share is already there
 	}
 
-	return nil
+	return err
 }
 
-func UploadFile(shareURL azfile.ShareURL, fileName *string, data *string, fileContentType *string) (azfile.FileURL, error) {
-	// Create a URL that references to root directory in your Azure Storage account's share.
+// DeleteFileShare deletes the share and everything in it. A share that doesn't exist is not an error, so it can be
+// called again safely. A share with snapshots is refused by the service; delete the snapshots first.
+func DeleteFileShare(ctx context.Context, shareURL azfile.ShareURL) error {
+	_, err := shareURL.Delete(ctx, azfile.DeleteSnapshotsOptionNone)
+
+	var stgErr azfile.StorageError
+	if errors.As(err, &stgErr) && stgErr.ServiceCode() == azfile.ServiceCodeShareNotFound {
+		return nil
+	}
+
+	return err
+}
+
+// ListShares lists every share of the account.
+func ListShares(ctx context.Context, serviceURL azfile.ServiceURL) ([]azfile.ShareItem, error) {
+	var results []azfile.ShareItem
+
+	for marker := (azfile.Marker{}); marker.NotDone(); { // The parentheses around azfile.Marker{} are required to avoid compiler error.
+		listResponse, err := serviceURL.ListSharesSegment(ctx, marker, azfile.ListSharesOptions{})
+		if err != nil {
+			return nil, err
+		}
+		marker = listResponse.NextMarker
+
+		results = append(results, listResponse.ShareItems...)
+	}
+
+	return results, nil
+}
+
+// SetShareQuota changes the maximum size of the share to quotaGiB.
+func SetShareQuota(ctx context.Context, shareURL azfile.ShareURL, quotaGiB int32) error {
+	_, err := shareURL.SetQuota(ctx, quotaGiB)
+	return err
+}
+
+// SetShareProvisionedSize provisions a premium share with sizeGiB. Premium shares are billed and scaled in performance
+// by their quota, so this is SetShareQuota under the name premium shares use.
+func SetShareProvisionedSize(ctx context.Context, shareURL azfile.ShareURL, sizeGiB int32) error {
+	// Premium shares are billed and performance-scaled by their quota, so provisioning is a quota change.
+	return SetShareQuota(ctx, shareURL, sizeGiB)
+}
+
+// GetShareStats returns the approximate number of bytes stored in the share. The quota is set in GiB, so compare the
+// usage against quota << 30. The service updates the figure lazily, so recent writes may not show yet.
+func GetShareStats(ctx context.Context, shareURL azfile.ShareURL) (usageBytes int64, err error) {
+	stats, err := shareURL.GetStatistics(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	return int64(stats.ShareUsageBytes), nil
+}
+
+// ShareProps holds the properties of a file share.
+type ShareProps struct {
+	QuotaGiB     int32
+	ETag         azfile.ETag
+	LastModified time.Time
+	Metadata     map[string]string
+}
+
+// GetShareProperties returns the quota, ETag, last modification time and metadata of the share. The space in use is
+// returned by GetShareStats.
+func GetShareProperties(ctx context.Context, shareURL azfile.ShareURL) (ShareProps, error) {
+	props, err := shareURL.GetProperties(ctx)
+	if err != nil {
+		return ShareProps{}, err
+	}
+
+	return ShareProps{
+		QuotaGiB:     props.Quota(),
+		ETag:         props.ETag(),
+		LastModified: props.LastModified(),
+		Metadata:     props.NewMetadata(),
+	}, nil
+}
+
+// UploadFile uploads length bytes read from data as fileName in directoryPath ("" for the share's root, or a path
+// such as "reports/2024"), creating the directories on the way that don't exist yet. The content is streamed in 4 MiB
+// ranges, so files of any size are uploaded without holding them in memory. data must yield exactly length bytes.
+func UploadFile(ctx context.Context, shareURL azfile.ShareURL, directoryPath string, fileName *string, data io.Reader, length int64, fileContentType *string) (azfile.FileURL, error) {
+	// Create the directory the file goes in, and a URL that references it.
 	// This returns a DirectoryURL object that wraps the directory's URL and a request pipeline (inherited from shareURL)
-	directoryURL := shareURL.NewRootDirectoryURL()
+	directoryURL, err := CreateDirectoryPath(ctx, shareURL, directoryPath)
+	if err != nil {
+		return azfile.FileURL{}, err
+	}
 
 	// Create a URL that references a to-be-created file in your Azure Storage account's directory.
 	// This returns a FileURL object that wraps the file's URL and a request pipeline (inherited from directoryURL)
 	fileURL := directoryURL.NewFileURL(*fileName) // File names can be mixed case and is case insensitive
 
-	// Create the file with string (plain text) content.
-	length := int64(len(*data))
-	_, err := fileURL.Create(ctx, length, azfile.FileHTTPHeaders{ContentType: *fileContentType}, azfile.Metadata{})
+	// Create the file at its final size; its content starts out zeroed.
+	_, err = fileURL.Create(ctx, length, azfile.FileHTTPHeaders{ContentType: *fileContentType}, azfile.Metadata{})
 	if err != nil {
 		return azfile.FileURL{}, err
 	}
 
-	_, err = fileURL.UploadRange(ctx, 0, strings.NewReader(*data), nil)
-	if err != nil {
-		return azfile.FileURL{}, err
+	// Fill it in one range at a time, the largest the service accepts in one request.
+	buf := make([]byte, azfile.FileMaxUploadRangeBytes)
+	for offset := int64(0); offset < length; {
+		chunk := buf
+		if remaining := length - offset; remaining < int64(len(chunk)) {
+			chunk = chunk[:remaining]
+		}
+		if _, err := io.ReadFull(data, chunk); err != nil {
+			return azfile.FileURL{}, fmt.Errorf("azurestorage: reading %s at offset %d: %w", *fileName, offset, err)
+		}
+
+		_, err = fileURL.UploadRange(ctx, offset, bytes.NewReader(chunk), nil)
+		if err != nil {
+			return azfile.FileURL{}, err
+		}
+		offset += int64(len(chunk))
 	}
 
 	return fileURL, nil
 }
 
-func DownloadFile(shareURL azfile.ShareURL, fileName *string) (string, error) {
+// UploadFileAutoType uploads a file like UploadFile, with its content type detected from fileName's extension, or from
+// sniffing the first bytes of data when the extension is unknown. data must yield exactly length bytes.
+func UploadFileAutoType(ctx context.Context, shareURL azfile.ShareURL, directoryPath string, fileName *string, data io.Reader, length int64) (azfile.FileURL, error) {
+	// Detect the content type from the file name's extension, falling back to sniffing the first bytes, which are
+	// then put back in front of the rest of the content
+	peek := make([]byte, sniffLength)
+	n, err := io.ReadFull(data, peek)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return azfile.FileURL{}, err
+	}
+	contentType := DetectContentType(*fileName, peek[:n])
+
+	return UploadFile(ctx, shareURL, directoryPath, fileName, io.MultiReader(bytes.NewReader(peek[:n]), data), length, &contentType)
+}
+
+// DownloadFile returns the content of fileName, in the share's root, as a string. It holds the whole file in memory
+// and a string can't carry binary content faithfully, so it's only meant for small text files; stream anything else
+// with DownloadFileStream.
+func DownloadFile(ctx context.Context, shareURL azfile.ShareURL, fileName *string) (string, error) {
+	return DownloadFileWithRetries(ctx, shareURL, fileName, azfile.RetryReaderOptions{MaxRetryRequests: 3})
+}
+
+// DownloadFileWithRetries downloads fileName like DownloadFile, resuming the download up to retry.MaxRetryRequests
+// times when the connection drops mid-body. A read that still fails, or ends before the whole file arrived, is
+// returned as an error rather than as truncated content.
+func DownloadFileWithRetries(ctx context.Context, shareURL azfile.ShareURL, fileName *string, retry azfile.RetryReaderOptions) (string, error) {
 	// Create a URL that references to root directory in your Azure Storage account's share.
 	// This returns a DirectoryURL object that wraps the directory's URL and a request pipeline (inherited from shareURL)
 	directoryURL := shareURL.NewRootDirectoryURL()
@@ -219,14 +1215,93 @@ func DownloadFile(shareURL azfile.ShareURL, fileName *string) (string, error) {
 	}
 
 	downloadedData := &bytes.Buffer{}
-	retryReader := get.Body(azfile.RetryReaderOptions{})
+	retryReader := get.Body(retry)
 	defer retryReader.Close() // The client must close the response body when finished with it
 
-	downloadedData.ReadFrom(retryReader)
+	n, err := downloadedData.ReadFrom(retryReader)
+	if err != nil {
+		return "", err
+	}
+	if n != get.ContentLength() {
+		return "", fmt.Errorf("azurestorage: downloaded %d of %d bytes of %s: %w", n, get.ContentLength(), *fileName, io.ErrUnexpectedEOF)
+	}
+
 	return downloadedData.String(), nil
 }
 
-func GetListFile(shareURL azfile.ShareURL) ([][]azfile.FileItem, error) {
+// DownloadFileStream returns the content of fileName in the directory path ("" for the share's root) as a stream,
+// resuming the download up to 3 times when the connection drops mid-body. The caller must close it.
+func DownloadFileStream(ctx context.Context, shareURL azfile.ShareURL, path, fileName string) (io.ReadCloser, error) {
+	// Create a URL that references the file inside the (possibly nested) directory of the share.
+	fileURL := getDirectoryURL(shareURL, path).NewFileURL(fileName) // File names can be mixed case and is case insensitive
+
+	get, err := fileURL.Download(ctx, 0, azfile.CountToEnd, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return get.Body(azfile.RetryReaderOptions{MaxRetryRequests: 3}), nil
+}
+
+// DownloadFileRange downloads count bytes of fileName in dirPath starting at offset. count is positive, or CountToEnd
+// (or 0) to read to the end; a negative offset or count returns ErrInvalidRange without sending a request. The caller
+// must close the body of the response.
+func DownloadFileRange(ctx context.Context, shareURL azfile.ShareURL, dirPath, fileName string, offset, count int64) (*azfile.RetryableDownloadResponse, error) {
+	// Accept CountToEnd as well as azblob's 0 for "to the end", and reject negative offsets or counts
+	count, err := fileRangeCount(offset, count)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create a URL that references the file inside the (possibly nested) directory of the share.
+	fileURL := getDirectoryURL(shareURL, dirPath).NewFileURL(fileName) // File names can be mixed case and is case insensitive
+
+	// Download count bytes of the file's contents, starting at offset
+	return fileURL.Download(ctx, offset, count, false)
+}
+
+// FileHeaders holds the HTTP headers, metadata and timestamps returned alongside a downloaded file.
+type FileHeaders struct {
+	ContentType        string
+	ContentLength      int64
+	ContentEncoding    string
+	ContentDisposition string
+	CacheControl       string
+	ContentMD5         []byte
+	ETag               azfile.ETag
+	LastModified       time.Time
+	Metadata           map[string]string
+}
+
+// DownloadFileWithHeaders opens fileName in dirPath for reading, together with its HTTP headers and metadata, read from
+// the same response. The caller must close the returned body.
+func DownloadFileWithHeaders(ctx context.Context, shareURL azfile.ShareURL, dirPath, fileName string) (io.ReadCloser, FileHeaders, error) {
+	// Create a URL that references the file inside the (possibly nested) directory of the share.
+	fileURL := getDirectoryURL(shareURL, dirPath).NewFileURL(fileName) // File names can be mixed case and is case insensitive
+
+	// Download the entire file; the response carries the file's headers so no separate GetProperties call is needed.
+	get, err := fileURL.Download(ctx, 0, azfile.CountToEnd, false)
+	if err != nil {
+		return nil, FileHeaders{}, err
+	}
+
+	headers := FileHeaders{
+		ContentType:        get.ContentType(),
+		ContentLength:      get.ContentLength(),
+		ContentEncoding:    get.ContentEncoding(),
+		ContentDisposition: get.ContentDisposition(),
+		CacheControl:       get.CacheControl(),
+		ContentMD5:         get.ContentMD5(),
+		ETag:               get.ETag(),
+		LastModified:       get.LastModified(),
+		Metadata:           get.NewMetadata(),
+	}
+
+	// The caller must close the returned body when finished with it
+	return get.Body(azfile.RetryReaderOptions{}), headers, nil
+}
+
+func GetListFile(ctx context.Context, shareURL azfile.ShareURL) ([][]azfile.FileItem, error) {
 	var results [][]azfile.FileItem
 
 	// Create a URL that references to root directory in your Azure Storage account's share.
@@ -238,7 +1313,7 @@ func GetListFile(shareURL azfile.ShareURL) ([][]azfile.FileItem, error) {
 		// Get a result segment starting with the file indicated by the current Marker.
 		listResponse, err := directoryURL.ListFilesAndDirectoriesSegment(ctx, marker, azfile.ListFilesAndDirectoriesOptions{})
 		if err != nil {
-			log.Fatal(err)
+			return nil, err
 		}
 		// IMPORTANT: ListFilesAndDirectoriesSegment returns the start of the next segment; you MUST use this to get
 		// the next segment (after processing the current result segment).
@@ -254,3 +1329,242 @@ func GetListFile(shareURL azfile.ShareURL) ([][]azfile.FileItem, error) {
 
 	return results, nil
 }
+
+// FileEntry is a file or directory found by ListFilesRecursive.
+type FileEntry struct {
+	Path  string // Relative to the share's root, with "/" between directories
+	IsDir bool
+	Size  int64 // Content length of a file; 0 for a directory
+}
+
+// ListFilesRecursive lists every file and directory below startPath ("" for the share's root), walking into each
+// subdirectory. maxDepth limits how far: 1 lists startPath's own entries only, 2 their children too, and so on; 0
+// means no limit. Entries are returned level by level.
+func ListFilesRecursive(ctx context.Context, shareURL azfile.ShareURL, startPath string, maxDepth int) ([]FileEntry, error) {
+	type pending struct {
+		path  string
+		depth int
+	}
+
+	var entries []FileEntry
+	dirs := []pending{{path: strings.Trim(startPath, "/"), depth: 1}}
+	for len(dirs) > 0 {
+		dir := dirs[0]
+		dirs = dirs[1:]
+		directoryURL := getDirectoryURL(shareURL, dir.path)
+
+		prefix := ""
+		if dir.path != "" {
+			prefix = dir.path + "/"
+		}
+
+		for marker := (azfile.Marker{}); marker.NotDone(); { // The parentheses around azfile.Marker{} are required to avoid compiler error.
+			listResponse, err := directoryURL.ListFilesAndDirectoriesSegment(ctx, marker, azfile.ListFilesAndDirectoriesOptions{})
+			if err != nil {
+				return nil, err
+			}
+			marker = listResponse.NextMarker
+
+			for _, fileEntry := range listResponse.FileItems {
+				entry := FileEntry{Path: prefix + fileEntry.Name}
+				if fileEntry.Properties != nil {
+					entry.Size = fileEntry.Properties.ContentLength
+				}
+				entries = append(entries, entry)
+			}
+			for _, dirEntry := range listResponse.DirectoryItems {
+				entries = append(entries, FileEntry{Path: prefix + dirEntry.Name, IsDir: true})
+				if maxDepth <= 0 || dir.depth < maxDepth {
+					dirs = append(dirs, pending{path: prefix + dirEntry.Name, depth: dir.depth + 1})
+				}
+			}
+		}
+	}
+
+	return entries, nil
+}
+
+// ShareSummary counts the files and directories of the whole share and adds up the size of the files, walking every
+// directory. Unlike GetShareStats, the figures are exact, at the cost of listing the share.
+func ShareSummary(ctx context.Context, shareURL azfile.ShareURL) (fileCount, dirCount int, totalBytes int64, err error) {
+	// Walk the share breadth-first, keeping only the running totals and the directories still to visit
+	dirs := []azfile.DirectoryURL{shareURL.NewRootDirectoryURL()}
+	for len(dirs) > 0 {
+		directoryURL := dirs[0]
+		dirs = dirs[1:]
+
+		for marker := (azfile.Marker{}); marker.NotDone(); { // The parentheses around azfile.Marker{} are required to avoid compiler error.
+			listResponse, err := directoryURL.ListFilesAndDirectoriesSegment(ctx, marker, azfile.ListFilesAndDirectoriesOptions{})
+			if err != nil {
+				return 0, 0, 0, err
+			}
+			marker = listResponse.NextMarker
+
+			for _, fileEntry := range listResponse.FileItems {
+				fileCount++
+				if fileEntry.Properties != nil {
+					totalBytes += fileEntry.Properties.ContentLength
+				}
+			}
+			for _, dirEntry := range listResponse.DirectoryItems {
+				dirCount++
+				dirs = append(dirs, directoryURL.NewDirectoryURL(dirEntry.Name))
+			}
+		}
+	}
+
+	return fileCount, dirCount, totalBytes, nil
+}
+
+// DeleteFileIfExists deletes fileName in dirPath and reports whether it deleted it: false, with no error, when the file
+// or one of its directories doesn't exist.
+func DeleteFileIfExists(ctx context.Context, shareURL azfile.ShareURL, dirPath, fileName string) (bool, error) {
+	// Create a URL that references the file inside the (possibly nested) directory of the share.
+	fileURL := getDirectoryURL(shareURL, dirPath).NewFileURL(fileName) // File names can be mixed case and is case insensitive
+
+	// Delete the file; a file (or parent directory) that is already gone is not an error.
+	_, err := fileURL.Delete(ctx)
+	if err != nil {
+		if isFileNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+// DeleteFileDirectoryIfExists deletes the directory at dirPath, which must be empty, and reports whether it deleted it:
+// false, with no error, when the directory or one of its parents doesn't exist.
+func DeleteFileDirectoryIfExists(ctx context.Context, shareURL azfile.ShareURL, dirPath string) (bool, error) {
+	// Create a URL that references the directory to delete; the directory must already be empty.
+	directoryURL := getDirectoryURL(shareURL, dirPath)
+
+	// Delete the directory; a directory (or parent directory) that is already gone is not an error.
+	_, err := directoryURL.Delete(ctx)
+	if err != nil {
+		if isFileNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+// DeleteFile deletes fileName in the directory path ("" for the share's root).
+func DeleteFile(ctx context.Context, shareURL azfile.ShareURL, path, fileName string) error {
+	// Create a URL that references the file inside the (possibly nested) directory of the share.
+	fileURL := getDirectoryURL(shareURL, path).NewFileURL(fileName) // File names can be mixed case and is case insensitive
+
+	_, err := fileURL.Delete(ctx)
+	return err
+}
+
+// DeleteDirectory deletes the directory at path. The service only deletes empty directories: with recursive, every
+// file and subdirectory below it is deleted first; without, a directory that isn't empty is left alone and the error
+// says how many files and subdirectories it still holds.
+func DeleteDirectory(ctx context.Context, shareURL azfile.ShareURL, path string, recursive bool) error {
+	if strings.Trim(path, "/") == "" {
+		return errors.New("azurestorage: the share's root directory can't be deleted")
+	}
+
+	if recursive {
+		entries, err := ListFilesRecursive(ctx, shareURL, path, 0)
+		if err != nil {
+			return err
+		}
+
+		// Delete the files first, concurrently, then the directories from the deepest level up
+		var dirs []string
+		var files []string
+		for _, entry := range entries {
+			if entry.IsDir {
+				dirs = append(dirs, entry.Path)
+			} else {
+				files = append(files, entry.Path)
+			}
+		}
+		errs := make([]error, len(files))
+		runConcurrently(len(files), defaultConcurrency, func(i int) {
+			slash := strings.LastIndex(files[i], "/")
+			dir, name := files[i][:slash+1], files[i][slash+1:]
+			_, err := getDirectoryURL(shareURL, dir).NewFileURL(name).Delete(ctx)
+			if err != nil && !isFileNotFound(err) {
+				errs[i] = fmt.Errorf("%s: %w", files[i], err)
+			}
+		})
+		if failed := compactErrors(errs); len(failed) > 0 {
+			return failed[0]
+		}
+		for i := len(dirs) - 1; i >= 0; i-- { // ListFilesRecursive lists level by level
+			if _, err := DeleteFileDirectoryIfExists(ctx, shareURL, dirs[i]); err != nil {
+				return fmt.Errorf("%s: %w", dirs[i], err)
+			}
+		}
+	}
+
+	_, err := getDirectoryURL(shareURL, path).Delete(ctx)
+	var stgErr azfile.StorageError
+	if errors.As(err, &stgErr) && stgErr.ServiceCode() == azfile.ServiceCodeDirectoryNotEmpty {
+		entries, listErr := ListFilesRecursive(ctx, shareURL, path, 1)
+		if listErr != nil {
+			return err
+		}
+		var files, dirs int
+		for _, entry := range entries {
+			if entry.IsDir {
+				dirs++
+			} else {
+				files++
+			}
+		}
+		return fmt.Errorf("azurestorage: directory %s still holds %d files and %d subdirectories; delete it recursively: %w", path, files, dirs, err)
+	}
+
+	return err
+}
+
+// CreateDirectoryPath creates the directory at path in the share, and every directory above it, skipping those that
+// already exist, and returns its URL. An empty path returns the share's root directory.
+func CreateDirectoryPath(ctx context.Context, shareURL azfile.ShareURL, path string) (azfile.DirectoryURL, error) {
+	// Directories can only be created inside an existing parent, so create them from the top down
+	parent := ""
+	for _, segment := range strings.Split(path, "/") {
+		if segment == "" {
+			continue
+		}
+		parent += segment + "/"
+		if err := createFileDirectory(ctx, shareURL, parent); err != nil {
+			return azfile.DirectoryURL{}, err
+		}
+	}
+
+	return getDirectoryURL(shareURL, path), nil
+}
+
+func getDirectoryURL(shareURL azfile.ShareURL, dirPath string) azfile.DirectoryURL {
+	// An empty path (or "/") references the share's root directory; every other segment is a nested directory.
+	directoryURL := shareURL.NewRootDirectoryURL()
+	for _, segment := range strings.Split(dirPath, "/") {
+		if segment == "" {
+			continue
+		}
+		directoryURL = directoryURL.NewDirectoryURL(segment)
+	}
+
+	return directoryURL
+}
+
+func isFileNotFound(err error) bool {
+	var stgErr azfile.StorageError
+	if !errors.As(err, &stgErr) {
+		return false
+	}
+
+	switch stgErr.ServiceCode() {
+	case azfile.ServiceCodeResourceNotFound, azfile.ServiceCodeParentNotFound:
+		return true
+	}
+	return false
+}
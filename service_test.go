@@ -0,0 +1,220 @@
+package azurestorage
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/Azure/azure-storage-file-go/azfile"
+)
+
+// failingPipeline returns a pipeline whose every request fails with err before reaching the network.
+func failingPipeline(err error) pipeline.Pipeline {
+	sender := pipeline.FactoryFunc(func(next pipeline.Policy, po *pipeline.PolicyOptions) pipeline.PolicyFunc {
+		return func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+			return nil, err
+		}
+	})
+
+	return pipeline.NewPipeline([]pipeline.Factory{pipeline.MethodFactoryMarker()}, pipeline.Options{HTTPSender: sender})
+}
+
+func failingShareURL(t *testing.T, err error) azfile.ShareURL {
+	t.Helper()
+
+	u, parseErr := url.Parse("https://account.file.core.windows.net/share")
+	if parseErr != nil {
+		t.Fatal(parseErr)
+	}
+
+	return azfile.NewShareURL(*u, failingPipeline(err))
+}
+
+func TestCreateFileShareReturnsNonStorageError(t *testing.T) {
+	wantErr := errors.New("connection reset")
+
+	defer func() {
+		if r := recover(); r != nil {
+			t.Fatalf("CreateFileShare panicked: %v", r)
+		}
+	}()
+
+	err := CreateFileShare(context.Background(), failingShareURL(t, wantErr), 0)
+	if !errors.Is(err, wantErr) {
+		t.Fatalf("CreateFileShare() error = %v, want %v", err, wantErr)
+	}
+}
+
+func TestGetFileServiceURL(t *testing.T) {
+	accountKey := "Eby8vdM02xNOcqFlqUwJPLlmEtlCDXJ1OUzFT50uSRZ6IFsuFq2UVErCz4I6tq/K1SZFPTOtr/KBHBeksoGMGw=="
+
+	tests := []struct {
+		name           string
+		accountName    string
+		fileServiceURL string
+		want           string
+	}{
+		{"public cloud", "myaccount", "https://%s.file.core.windows.net", "https://myaccount.file.core.windows.net"},
+		{"sovereign cloud", "myaccount", "https://%s.file.core.chinacloudapi.cn/", "https://myaccount.file.core.chinacloudapi.cn/"},
+		{"emulator", "devstoreaccount1", "http://127.0.0.1:10004/%s", "http://127.0.0.1:10004/devstoreaccount1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			serviceURL, err := GetFileService(&tt.accountName, &accountKey, &tt.fileServiceURL)
+			if err != nil {
+				t.Fatalf("GetFileService() error = %v", err)
+			}
+
+			u := serviceURL.URL()
+			if got := u.String(); got != tt.want {
+				t.Errorf("GetFileService() URL = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetListFileReturnsListingError(t *testing.T) {
+	wantErr := errors.New("listing failed")
+
+	results, err := GetListFile(context.Background(), failingShareURL(t, wantErr))
+	if !errors.Is(err, wantErr) {
+		t.Fatalf("GetListFile() error = %v, want %v", err, wantErr)
+	}
+	if results != nil {
+		t.Errorf("GetListFile() results = %v, want nil", results)
+	}
+}
+
+// trackingBody is a response body that records whether it was closed.
+type trackingBody struct {
+	io.Reader
+	closed bool
+}
+
+func (b *trackingBody) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestNewContentDecoder(t *testing.T) {
+	// "deflate, gzip": deflated first, then gzipped
+	var deflated bytes.Buffer
+	zw := zlib.NewWriter(&deflated)
+	zw.Write([]byte("hello"))
+	zw.Close()
+	var encoded bytes.Buffer
+	gw := gzip.NewWriter(&encoded)
+	gw.Write(deflated.Bytes())
+	gw.Close()
+
+	body := &trackingBody{Reader: bytes.NewReader(encoded.Bytes())}
+	decoded, err := newContentDecoder("deflate, gzip", body)
+	if err != nil {
+		t.Fatalf("newContentDecoder() error = %v", err)
+	}
+	got, err := io.ReadAll(decoded)
+	if err != nil || string(got) != "hello" {
+		t.Errorf("decoded = %q, %v, want %q", got, err, "hello")
+	}
+	if err := decoded.Close(); err != nil || !body.closed {
+		t.Errorf("Close() = %v, body closed = %v, want the body closed", err, body.closed)
+	}
+}
+
+func TestNewContentDecoderRejectsUnknownEncoding(t *testing.T) {
+	var encoded bytes.Buffer
+	gw := gzip.NewWriter(&encoded)
+	gw.Write([]byte("hello"))
+	gw.Close()
+
+	body := &trackingBody{Reader: bytes.NewReader(encoded.Bytes())}
+	if _, err := newContentDecoder("compress, gzip", body); err == nil {
+		t.Fatal("newContentDecoder() error = nil, want an error for the unsupported encoding")
+	}
+	if body.closed {
+		t.Error("body closed on error, want it left to the caller")
+	}
+}
+
+func TestUploadBlobIfChangedFromOffset(t *testing.T) {
+	var uploaded []byte
+	sender := &recordingSender{respond: func(request *http.Request) *http.Response {
+		if request.Method == http.MethodHead {
+			header := http.Header{}
+			header.Set("x-ms-error-code", string(azblob.ServiceCodeBlobNotFound))
+			return &http.Response{StatusCode: http.StatusNotFound, Header: header}
+		}
+		uploaded, _ = io.ReadAll(request.Body)
+		return &http.Response{StatusCode: http.StatusCreated, Header: http.Header{}}
+	}}
+	u, err := url.Parse("https://account.blob.core.windows.net/container")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := pipeline.NewPipeline([]pipeline.Factory{pipeline.MethodFactoryMarker()}, pipeline.Options{HTTPSender: sender})
+
+	data := strings.NewReader("header|payload")
+	data.Seek(int64(len("header|")), io.SeekStart)
+
+	changed, err := UploadBlobIfChanged(context.Background(), azblob.NewContainerURL(*u, p), "blob.txt", data, "text/plain")
+	if err != nil {
+		t.Fatalf("UploadBlobIfChanged() error = %v", err)
+	}
+	if !changed {
+		t.Fatal("UploadBlobIfChanged() = false, want the missing blob uploaded")
+	}
+
+	if string(uploaded) != "payload" {
+		t.Errorf("uploaded %q, want %q", uploaded, "payload")
+	}
+	sum := md5.Sum([]byte("payload"))
+	upload := sender.requests[len(sender.requests)-1]
+	if got, want := upload.Header.Get("x-ms-blob-content-md5"), base64.StdEncoding.EncodeToString(sum[:]); got != want {
+		t.Errorf("x-ms-blob-content-md5 = %q, want %q", got, want)
+	}
+}
+
+func TestUploadBlobIdempotentConcurrentDelivery(t *testing.T) {
+	heads := 0
+	sender := &recordingSender{respond: func(request *http.Request) *http.Response {
+		header := http.Header{}
+		switch request.Method {
+		case http.MethodHead:
+			// Absent when first checked, then written by the other delivery of the same operation
+			heads++
+			if heads == 1 {
+				header.Set("x-ms-error-code", string(azblob.ServiceCodeBlobNotFound))
+				return &http.Response{StatusCode: http.StatusNotFound, Header: header}
+			}
+			header.Set("x-ms-meta-"+IdempotencyKeyMetadata, "event-1")
+			return &http.Response{StatusCode: http.StatusOK, Header: header}
+		default:
+			header.Set("x-ms-error-code", string(azblob.ServiceCodeBlobAlreadyExists))
+			return &http.Response{StatusCode: http.StatusConflict, Header: header}
+		}
+	}}
+	u, err := url.Parse("https://account.blob.core.windows.net/container")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := pipeline.NewPipeline([]pipeline.Factory{pipeline.MethodFactoryMarker()}, pipeline.Options{HTTPSender: sender})
+
+	uploaded, err := UploadBlobIdempotent(context.Background(), azblob.NewContainerURL(*u, p), "blob.txt", strings.NewReader("data"), "event-1")
+	if err != nil || uploaded {
+		t.Fatalf("UploadBlobIdempotent() = %v, %v, want false, nil for the blob the other delivery uploaded", uploaded, err)
+	}
+	if put := sender.requests[1]; put.Header.Get("If-None-Match") != "*" {
+		t.Errorf("upload If-None-Match = %q, want a create-only upload", put.Header.Get("If-None-Match"))
+	}
+}
@@ -0,0 +1,193 @@
+package azurestorage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+)
+
+// ================================================================================================================================================
+// Azure Storage - BLOB Index Tag Functions
+// ================================================================================================================================================
+
+// ErrPreconditionFailed is returned by the conditional writes, SetBlobTags, UploadBlobConditional and
+// SetBlobHTTPHeaders, when the blob no longer matches what the caller expected.
+var ErrPreconditionFailed = errors.New("azurestorage: precondition failed")
+
+// ErrInvalidTag is returned (wrapped with the details) for a set of blob index tags the service would refuse.
+var ErrInvalidTag = errors.New("azurestorage: invalid blob index tag")
+
+// Limits of blob index tags.
+const (
+	maxBlobTags        = 10
+	maxBlobTagKeyLen   = 128
+	maxBlobTagValueLen = 256
+)
+
+// FindBlobsByTags returns one page of the blobs in the account whose index tags match tagQuery, starting at marker,
+// and the marker of the next page; pass an empty Marker for the first page. maxResults caps the page size, or lets
+// the service pick it (up to 5000) when 0. To get every match, use FindBlobsByTag, which collects all the pages, or
+// WalkBlobsByTags, which calls a function for each blob as the pages are read.
+func FindBlobsByTags(ctx context.Context, serviceURL azblob.ServiceURL, tagQuery string, marker azblob.Marker, maxResults int32) ([]azblob.FilterBlobItem, azblob.Marker, error) {
+	// A maxResults of 0 lets the service pick the page size (up to 5000 blobs)
+	var max *int32
+	if maxResults > 0 {
+		max = &maxResults
+	}
+
+	// Get one page of the blobs, across all containers, whose tags match the query
+	segment, err := serviceURL.FindBlobsByTags(ctx, nil, nil, &tagQuery, marker, max)
+	if err != nil {
+		return nil, azblob.Marker{}, err
+	}
+
+	// A missing NextMarker means there are no more pages
+	next := segment.NextMarker
+	if next == nil {
+		done := ""
+		next = &done
+	}
+
+	return segment.Blobs, azblob.Marker{Val: next}, nil
+}
+
+// FindBlobsByTag returns every blob in the account whose index tags match query, for example "project = 'alpha'",
+// reading all the pages of results.
+func FindBlobsByTag(ctx context.Context, serviceURL azblob.ServiceURL, query string) ([]azblob.FilterBlobItem, error) {
+	var results []azblob.FilterBlobItem
+	err := WalkBlobsByTags(ctx, serviceURL, query, func(item azblob.FilterBlobItem) error {
+		results = append(results, item)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// WalkBlobsByTags calls fn for every blob in the account whose index tags match tagQuery, following the continuation
+// markers page by page, so only one page is held in memory. An error from fn stops the walk and is returned. Unlike
+// FindBlobsByTag, it doesn't collect the results.
+func WalkBlobsByTags(ctx context.Context, serviceURL azblob.ServiceURL, tagQuery string, fn func(azblob.FilterBlobItem) error) error {
+	// Stream every matching blob to fn one page at a time; an error from fn stops the walk and is returned
+	for marker := (azblob.Marker{}); marker.NotDone(); { // The parens around Marker{} are required to avoid compiler error.
+		items, next, err := FindBlobsByTags(ctx, serviceURL, tagQuery, marker, 0)
+		if err != nil {
+			return err
+		}
+		marker = next
+
+		for _, item := range items {
+			if err := fn(item); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// SetBlobTags replaces the index tags of blobName with tags. When expected is non-empty the update is conditional: it
+// only applies if the blob still carries every tag in expected with the same value, and fails with
+// ErrPreconditionFailed otherwise. Setting tags doesn't change a blob's ETag, so If-Match can't detect a concurrent
+// tag update; read the tags with GetBlobTags and pass them as expected to get the same optimistic concurrency.
+func SetBlobTags(ctx context.Context, containerURL azblob.ContainerURL, blobName string, tags, expected map[string]string) error {
+	blobURL := containerURL.NewBlobURL(blobName)
+
+	if err := ValidateBlobTags(tags); err != nil {
+		return err
+	}
+
+	// The expected tags become part of a query expression, so they must follow the same rules
+	var ifTags *string
+	if len(expected) > 0 {
+		if err := ValidateBlobTags(expected); err != nil {
+			return err
+		}
+		condition := tagCondition(expected)
+		ifTags = &condition
+	}
+
+	_, err := blobURL.SetTags(ctx, nil, nil, ifTags, tags)
+	if isConditionNotMet(err) {
+		return fmt.Errorf("%w: tags of %s changed", ErrPreconditionFailed, blobName)
+	}
+
+	return err
+}
+
+// GetBlobTags returns the index tags of blobName.
+func GetBlobTags(ctx context.Context, containerURL azblob.ContainerURL, blobName string) (map[string]string, error) {
+	blobURL := containerURL.NewBlobURL(blobName)
+
+	resp, err := blobURL.GetTags(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	tags := map[string]string{}
+	for _, tag := range resp.BlobTagSet {
+		tags[tag.Key] = tag.Value
+	}
+
+	return tags, nil
+}
+
+// ValidateBlobTags checks tags against the service's rules: at most 10 tags, keys of 1 to 128 and values of up to 256
+// characters, made of letters, digits, spaces and + - . / : = _ only.
+func ValidateBlobTags(tags map[string]string) error {
+	if len(tags) > maxBlobTags {
+		return fmt.Errorf("%w: %d tags, at most %d are allowed", ErrInvalidTag, len(tags), maxBlobTags)
+	}
+	for key, value := range tags {
+		if key == "" || len(key) > maxBlobTagKeyLen {
+			return fmt.Errorf("%w: key %q must be 1 to %d characters", ErrInvalidTag, key, maxBlobTagKeyLen)
+		}
+		if len(value) > maxBlobTagValueLen {
+			return fmt.Errorf("%w: value of %q must be at most %d characters", ErrInvalidTag, key, maxBlobTagValueLen)
+		}
+		if !isTagText(key) {
+			return fmt.Errorf("%w: key %q may only hold letters, digits, spaces and + - . / : = _", ErrInvalidTag, key)
+		}
+		if !isTagText(value) {
+			return fmt.Errorf("%w: value %q of %q may only hold letters, digits, spaces and + - . / : = _", ErrInvalidTag, value, key)
+		}
+	}
+
+	return nil
+}
+
+func isTagText(s string) bool {
+	for _, c := range s {
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case strings.ContainsRune(" +-./:=_", c):
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// tagCondition builds the x-ms-if-tags expression requiring every tag in tags. Valid tag keys and values can't contain
+// quotes; they are still escaped by doubling, so no key or value can end its literal early and change the expression.
+func tagCondition(tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys) // A stable expression keeps requests reproducible in logs
+
+	clauses := make([]string, len(keys))
+	for i, key := range keys {
+		clauses[i] = fmt.Sprintf("\"%s\" = '%s'", strings.ReplaceAll(key, `"`, `""`), strings.ReplaceAll(tags[key], "'", "''"))
+	}
+
+	return strings.Join(clauses, " AND ")
+}
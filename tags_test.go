@@ -0,0 +1,50 @@
+package azurestorage
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-blob-go/azblob"
+)
+
+func TestTagCondition(t *testing.T) {
+	tests := []struct {
+		name string
+		tags map[string]string
+		want string
+	}{
+		{"single", map[string]string{"project": "alpha"}, `"project" = 'alpha'`},
+		{"sorted", map[string]string{"b": "2", "a": "1"}, `"a" = '1' AND "b" = '2'`},
+		{"quotes escaped", map[string]string{`k"ey`: "x' OR 'a'='a"}, `"k""ey" = 'x'' OR ''a''=''a'`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tagCondition(tt.tags); got != tt.want {
+				t.Errorf("tagCondition() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetBlobTagsRejectsInvalidExpectedTags(t *testing.T) {
+	sender := &recordingSender{respond: func(request *http.Request) *http.Response {
+		return &http.Response{StatusCode: http.StatusNoContent, Header: http.Header{}}
+	}}
+	u, err := url.Parse("https://account.blob.core.windows.net/container")
+	if err != nil {
+		t.Fatal(err)
+	}
+	containerURL := azblob.NewContainerURL(*u, pipeline.NewPipeline([]pipeline.Factory{pipeline.MethodFactoryMarker()}, pipeline.Options{HTTPSender: sender}))
+
+	err = SetBlobTags(context.Background(), containerURL, "blob.txt", map[string]string{"state": "done"}, map[string]string{"state": "x' OR 'a'='a"})
+	if !errors.Is(err, ErrInvalidTag) {
+		t.Fatalf("SetBlobTags() error = %v, want %v", err, ErrInvalidTag)
+	}
+	if len(sender.requests) != 0 {
+		t.Errorf("sent %d requests, want none", len(sender.requests))
+	}
+}
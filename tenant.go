@@ -0,0 +1,144 @@
+package azurestorage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-blob-go/azblob"
+)
+
+// ================================================================================================================================================
+// Azure Storage - Tenant Isolation
+// ================================================================================================================================================
+
+// ErrTenantScope is returned for a request that a service created with TenantScope can't confine to the tenant.
+var ErrTenantScope = errors.New("azurestorage: operation is outside the tenant scope")
+
+// ErrInvalidTenantID is returned (wrapped with the details) by the functions creating a service when the ID passed to
+// TenantScope can't start a container or share name.
+var ErrInvalidTenantID = errors.New("azurestorage: invalid tenant ID")
+
+// maxTenantIDLen leaves room for the hyphen and a 3 character name within the 63 character limit of container names.
+const maxTenantIDLen = 63 - 1 - 3
+
+// TenantScope confines the service to the containers (or shares) of one tenant. Every container name is prefixed
+// with tenantID and a hyphen on the wire, so GetBlobContainer(serviceURL, &name) with name "invoices" works on
+// "<tenantID>-invoices", and listing the containers only returns the tenant's own (still carrying the prefix).
+// Copy sources in the same account are prefixed too, so a copy can't read another tenant's data. Account-wide
+// operations that can't be confined, such as service properties or a tag search across containers, fail with
+// ErrTenantScope. tenantID must be 1 to 59 lowercase letters, digits and hyphens, starting and ending with a letter or
+// digit and with no consecutive hyphens, so that it can start any container name; the service is not created, with
+// ErrInvalidTenantID, otherwise. The shorter tenantID, the longer the container names it leaves room for.
+func TenantScope(tenantID string) Option {
+	return func(o *serviceOptions) {
+		if err := validateTenantID(tenantID); err != nil {
+			o.fail(err)
+			return
+		}
+		o.tenantPrefix = tenantID + "-"
+	}
+}
+
+// validateTenantID checks that tenantID followed by a hyphen is a valid start of a container name. This also rules
+// out IDs such as "../other" or "a/b" that could make the prefixed path reach outside the tenant's containers.
+func validateTenantID(tenantID string) error {
+	if tenantID == "" || len(tenantID) > maxTenantIDLen {
+		return fmt.Errorf("%w: %q must be 1 to %d characters", ErrInvalidTenantID, tenantID, maxTenantIDLen)
+	}
+	for _, c := range tenantID {
+		if !('a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-') {
+			return fmt.Errorf("%w: %q may only hold lowercase letters, digits and hyphens", ErrInvalidTenantID, tenantID)
+		}
+	}
+	if strings.HasPrefix(tenantID, "-") || strings.HasSuffix(tenantID, "-") || strings.Contains(tenantID, "--") {
+		return fmt.Errorf("%w: %q must start and end with a letter or digit and have no consecutive hyphens", ErrInvalidTenantID, tenantID)
+	}
+
+	return nil
+}
+
+func newTenantScopePolicyFactory(prefix string) pipeline.Factory {
+	return pipeline.FactoryFunc(func(next pipeline.Policy, po *pipeline.PolicyOptions) pipeline.PolicyFunc {
+		return func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+			scoped, err := scopeURL(request.URL, prefix)
+			if err != nil {
+				return nil, err
+			}
+			if !scoped {
+				// Listing the containers (or shares) is the only account-wide operation that can be confined.
+				query := request.URL.Query()
+				if request.Method != http.MethodGet || query.Get("comp") != "list" {
+					return nil, fmt.Errorf("%w: account-wide operation %s", ErrTenantScope, operationName(request))
+				}
+				query.Set("prefix", prefix+query.Get("prefix"))
+				request.URL.RawQuery = query.Encode()
+			}
+
+			// A copy within the account reads its source through the same tenant prefix
+			if source := request.Header.Get("x-ms-copy-source"); source != "" {
+				sourceURL, err := url.Parse(source)
+				if err != nil {
+					return nil, err
+				}
+				if sourceURL.Host == request.URL.Host {
+					scoped, err := scopeURL(sourceURL, prefix)
+					if err != nil {
+						return nil, err
+					}
+					if !scoped {
+						return nil, fmt.Errorf("%w: copy source %s names no container", ErrTenantScope, source)
+					}
+					request.Header.Set("x-ms-copy-source", sourceURL.String())
+				}
+			}
+
+			return next.Do(ctx, request)
+		}
+	})
+}
+
+// scopeURL prefixes the container (or share) named by u's path. It returns false when the path names no container.
+func scopeURL(u *url.URL, prefix string) (bool, error) {
+	parts := azblob.NewBlobURLParts(*u)
+	ipStyle := parts.IPEndpointStyleInfo.AccountName != ""
+
+	path, scoped := prefixContainerSegment(u.Path, prefix, ipStyle)
+	if !scoped {
+		return false, nil
+	}
+	if strings.HasPrefix(parts.ContainerName, "$") {
+		// Special containers such as $root and $web are shared by the whole account
+		return false, fmt.Errorf("%w: special container %s", ErrTenantScope, parts.ContainerName)
+	}
+
+	u.Path = path
+	if u.RawPath != "" {
+		u.RawPath, _ = prefixContainerSegment(u.RawPath, prefix, ipStyle)
+	}
+	return true, nil
+}
+
+func prefixContainerSegment(path, prefix string, ipStyle bool) (string, bool) {
+	start := 0
+	if strings.HasPrefix(path, "/") {
+		start = 1
+	}
+	if ipStyle {
+		// The account name comes first in the path of an IP-style (emulator) URL
+		i := strings.Index(path[start:], "/")
+		if i == -1 {
+			return path, false
+		}
+		start += i + 1
+	}
+	if start >= len(path) || path[start] == '/' {
+		return path, false
+	}
+
+	return path[:start] + prefix + path[start:], true
+}
@@ -0,0 +1,42 @@
+package azurestorage
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestTenantScopeValidatesTenantID(t *testing.T) {
+	accountName := "myaccount"
+	accountKey := "Eby8vdM02xNOcqFlqUwJPLlmEtlCDXJ1OUzFT50uSRZ6IFsuFq2UVErCz4I6tq/K1SZFPTOtr/KBHBeksoGMGw=="
+	blobServiceURL := "https://%s.blob.core.windows.net"
+
+	tests := []struct {
+		tenantID string
+		valid    bool
+	}{
+		{"acme", true},
+		{"tenant-42", true},
+		{"", false},
+		{"../other", false},
+		{"a/b", false},
+		{"..", false},
+		{"Acme", false},
+		{"acme_corp", false},
+		{"-acme", false},
+		{"acme-", false},
+		{"ac--me", false},
+		{strings.Repeat("a", maxTenantIDLen+1), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tenantID, func(t *testing.T) {
+			_, err := GetBlobService(&accountName, &accountKey, &blobServiceURL, TenantScope(tt.tenantID))
+			if tt.valid && err != nil {
+				t.Errorf("GetBlobService() error = %v, want nil", err)
+			}
+			if !tt.valid && !errors.Is(err, ErrInvalidTenantID) {
+				t.Errorf("GetBlobService() error = %v, want %v", err, ErrInvalidTenantID)
+			}
+		})
+	}
+}
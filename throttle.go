@@ -0,0 +1,85 @@
+package azurestorage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+)
+
+// ================================================================================================================================================
+// Azure Storage - Throttling Errors
+// ================================================================================================================================================
+
+// ErrThrottled matches every ThrottledError with errors.Is.
+var ErrThrottled = errors.New("azurestorage: request throttled")
+
+// ThrottledError is returned, wrapping the storage error, when the service still throttles a request (429 Too Many
+// Requests or 503 Server Busy) after the pipeline's own retries are spent. Use errors.As to get at RetryAfter.
+type ThrottledError struct {
+	Err        error
+	StatusCode int
+	retryAfter time.Duration
+}
+
+func (e *ThrottledError) Error() string {
+	return fmt.Sprintf("azurestorage: throttled with status %d: %v", e.StatusCode, e.Err)
+}
+
+func (e *ThrottledError) Unwrap() error {
+	return e.Err
+}
+
+func (e *ThrottledError) Is(target error) bool {
+	return target == ErrThrottled
+}
+
+// RetryAfter returns how long the service asked the client to wait before trying again, or 0 when the response
+// didn't say.
+func (e *ThrottledError) RetryAfter() time.Duration {
+	return e.retryAfter
+}
+
+func newThrottlePolicyFactory() pipeline.Factory {
+	return pipeline.FactoryFunc(func(next pipeline.Policy, po *pipeline.PolicyOptions) pipeline.PolicyFunc {
+		return func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+			response, err := next.Do(ctx, request)
+			if err == nil {
+				return response, nil
+			}
+
+			// Both azblob.StorageError and azfile.StorageError carry the failed response
+			var responder interface{ Response() *http.Response }
+			if !errors.As(err, &responder) || responder.Response() == nil {
+				return response, err
+			}
+			raw := responder.Response()
+			if raw.StatusCode != http.StatusTooManyRequests && raw.StatusCode != http.StatusServiceUnavailable {
+				return response, err
+			}
+
+			return response, &ThrottledError{Err: err, StatusCode: raw.StatusCode, retryAfter: parseRetryAfter(raw.Header.Get("Retry-After"))}
+		}
+	})
+}
+
+// parseRetryAfter reads a Retry-After header, which holds either a number of seconds or an HTTP date.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if wait := time.Until(date); wait > 0 {
+			return wait
+		}
+	}
+
+	return 0
+}
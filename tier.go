@@ -0,0 +1,83 @@
+package azurestorage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-blob-go/azblob"
+)
+
+// ================================================================================================================================================
+// Azure Storage - BLOB Access Tiers
+// ================================================================================================================================================
+
+// ErrBlobArchived matches, with errors.Is, the error of any read of a blob in the Archive tier. The error still
+// unwraps to the azblob.StorageError.
+var ErrBlobArchived = errors.New("azurestorage: blob is archived and must be rehydrated to Hot or Cool before it can be read (see RehydrateBlob and RehydrateBlobViaCopy)")
+
+type archivedError struct {
+	err error
+}
+
+func (e *archivedError) Error() string {
+	return fmt.Sprintf("%v: %v", ErrBlobArchived, e.err)
+}
+
+func (e *archivedError) Unwrap() error {
+	return e.err
+}
+
+func (e *archivedError) Is(target error) bool {
+	return target == ErrBlobArchived
+}
+
+// SetBlobTier moves the blob to tier. Moving it to Archive takes effect at once, after which reads fail with
+// ErrBlobArchived; moving it out of Archive starts a rehydration, see RehydrateBlob.
+func SetBlobTier(ctx context.Context, blobURL azblob.BlockBlobURL, tier azblob.AccessTierType) error {
+	_, err := blobURL.SetTier(ctx, tier, azblob.LeaseAccessConditions{})
+	return err
+}
+
+// RehydrateBlob starts moving an archived blob back to tier (Hot or Cool) in place, and returns the priority the
+// service is rehydrating it with: asking for High while a Standard rehydration is pending upgrades it, but never the
+// reverse. The blob stays unreadable until the rehydration finishes, which takes up to 15 hours at Standard priority
+// and usually under one hour at High; VerifyBlobTiers reports its progress. priority is only sent for a blob obtained
+// through GetBlobContainer, as the azblob SDK always sets tiers without one.
+func RehydrateBlob(ctx context.Context, blobURL azblob.BlockBlobURL, tier azblob.AccessTierType, priority azblob.RehydratePriorityType) (azblob.RehydratePriorityType, error) {
+	if tier != azblob.AccessTierHot && tier != azblob.AccessTierCool {
+		return azblob.RehydratePriorityNone, fmt.Errorf("azurestorage: cannot rehydrate to access tier %q", tier)
+	}
+
+	if priority != azblob.RehydratePriorityNone {
+		ctx = context.WithValue(ctx, rehydratePriorityKey{}, priority)
+	}
+	_, err := blobURL.SetTier(ctx, tier, azblob.LeaseAccessConditions{})
+	if err != nil {
+		return azblob.RehydratePriorityNone, err
+	}
+
+	props, err := blobURL.GetProperties(ctx, azblob.BlobAccessConditions{}, azblob.ClientProvidedKeyOptions{})
+	if err != nil {
+		return azblob.RehydratePriorityNone, err
+	}
+
+	return azblob.RehydratePriorityType(props.RehydratePriority()), nil
+}
+
+// newArchivedPolicyFactory turns the service's refusal to read an archived blob into an error that says what to do.
+func newArchivedPolicyFactory() pipeline.Factory {
+	return pipeline.FactoryFunc(func(next pipeline.Policy, po *pipeline.PolicyOptions) pipeline.PolicyFunc {
+		return func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+			response, err := next.Do(ctx, request)
+
+			var stgErr azblob.StorageError
+			if err != nil && errors.As(err, &stgErr) && stgErr.ServiceCode() == azblob.ServiceCodeBlobArchived {
+				return response, &archivedError{err: err}
+			}
+
+			return response, err
+		}
+	})
+}
@@ -0,0 +1,76 @@
+package azurestorage
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+)
+
+// ================================================================================================================================================
+// Azure Storage - Azure AD Token Credentials
+// ================================================================================================================================================
+
+const (
+	tokenRefreshMargin     = 2 * time.Minute  // How long before its expiry a token is replaced
+	tokenRefreshRetryDelay = 30 * time.Second // How soon a failed refresh is tried again
+)
+
+// GetBlobServiceWithTokenCredential returns the blob service of accountName authorised with an Azure AD token, for
+// managed identities, workload identities and service principals where no account key exists. blobServiceURL is a
+// format with one %s for the account name, like GetBlobService's. Use NewRefreshingTokenCredential so a long-lived
+// service keeps working past the expiry of its first token.
+func GetBlobServiceWithTokenCredential(accountName string, tokenCredential azblob.TokenCredential, blobServiceURL string, options ...Option) (azblob.ServiceURL, error) {
+	u, err := url.Parse(fmt.Sprintf(blobServiceURL, accountName))
+	if err != nil {
+		return azblob.ServiceURL{}, err
+	}
+	p, err := newBlobPipeline(tokenCredential, options)
+	if err != nil {
+		return azblob.ServiceURL{}, err
+	}
+
+	return azblob.NewServiceURL(*u, p), nil
+}
+
+// NewRefreshingTokenCredential returns a token credential that gets its tokens from fetch, for example a wrapper
+// around azidentity's GetToken with the "https://storage.azure.com/.default" scope. The first token is fetched before
+// returning; after that fetch is called in the background shortly before each token expires. A failed refresh is
+// retried every 30 seconds while the current token is kept, so requests only fail once it has really expired.
+func NewRefreshingTokenCredential(ctx context.Context, fetch func(ctx context.Context) (token string, expiresOn time.Time, err error)) (azblob.TokenCredential, error) {
+	token, expiresOn, err := fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// NewTokenCredential calls the refresher straight away; that first call only schedules the refresh of the first token
+	first := true
+	credential := azblob.NewTokenCredential(token, func(credential azblob.TokenCredential) time.Duration {
+		if first {
+			first = false
+			return refreshDelay(expiresOn)
+		}
+
+		token, expiresOn, err := fetch(context.Background()) // The refresh outlives the caller's context
+		if err != nil {
+			return tokenRefreshRetryDelay
+		}
+		credential.SetToken(token)
+
+		return refreshDelay(expiresOn)
+	})
+
+	return credential, nil
+}
+
+// refreshDelay returns how long to wait before replacing a token that expires at expiresOn.
+func refreshDelay(expiresOn time.Time) time.Duration {
+	wait := time.Until(expiresOn) - tokenRefreshMargin
+	if wait < tokenRefreshRetryDelay {
+		wait = tokenRefreshRetryDelay
+	}
+
+	return wait
+}
@@ -0,0 +1,124 @@
+package azurestorage
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+)
+
+// ================================================================================================================================================
+// Azure Storage - BLOB Directory Upload
+// ================================================================================================================================================
+
+// UploadDirectoryResumable uploads every regular file below localDir to containerURL, naming each blob prefix followed
+// by the file's slash-separated path relative to localDir. Each uploaded file is appended to the log at checkpointPath;
+// when the upload is restarted with the same checkpoint, files recorded there with an unchanged size are skipped.
+// Failed files don't stop the others, so a rerun only needs to upload what is missing.
+func UploadDirectoryResumable(ctx context.Context, containerURL azblob.ContainerURL, localDir, prefix, checkpointPath string, concurrency int) error {
+	done, err := readUploadCheckpoint(checkpointPath)
+	if err != nil {
+		return err
+	}
+
+	// Collect the files that still have to be uploaded.
+	var pending []string
+	var sizes []int64
+	err = filepath.WalkDir(localDir, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(localDir, filePath)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if size, ok := done[rel]; ok && size == info.Size() {
+			return nil // Already uploaded and unchanged since
+		}
+		pending = append(pending, rel)
+		sizes = append(sizes, info.Size())
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// The checkpoint is an append-only log: one "<size> <relative path>" line per uploaded file.
+	checkpoint, err := os.OpenFile(checkpointPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer checkpoint.Close()
+	var checkpointMu sync.Mutex
+
+	errs := make([]error, len(pending))
+	runConcurrently(len(pending), concurrency, func(i int) {
+		rel := pending[i]
+		blobName := path.Join(prefix, rel)
+		filePath := filepath.Join(localDir, filepath.FromSlash(rel))
+		if _, err := UploadBlobFromFile(ctx, containerURL, &blobName, &filePath); err != nil {
+			errs[i] = fmt.Errorf("%s: %w", rel, err)
+			return
+		}
+
+		checkpointMu.Lock()
+		defer checkpointMu.Unlock()
+		if _, err := fmt.Fprintf(checkpoint, "%d %s\n", sizes[i], rel); err != nil {
+			errs[i] = fmt.Errorf("%s: uploaded but not checkpointed: %w", rel, err)
+		}
+	})
+
+	failed := compactErrors(errs)
+	if len(failed) > 0 {
+		return fmt.Errorf("azurestorage: %d of %d files failed to upload, first error: %w", len(failed), len(pending), failed[0])
+	}
+
+	return nil
+}
+
+func readUploadCheckpoint(checkpointPath string) (map[string]int64, error) {
+	done := map[string]int64{}
+
+	file, err := os.Open(checkpointPath)
+	if os.IsNotExist(err) {
+		return done, nil // First run
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// A line torn by a crash while it was written doesn't parse and is ignored; its file is uploaded again.
+		fields := strings.SplitN(scanner.Text(), " ", 2)
+		if len(fields) != 2 {
+			continue
+		}
+		size, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		done[fields[1]] = size
+	}
+
+	return done, scanner.Err()
+}
@@ -0,0 +1,71 @@
+package azurestorage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+)
+
+// ================================================================================================================================================
+// Azure Storage - BLOB Wait Functions
+// ================================================================================================================================================
+
+// ErrWaitTimeout is returned by WaitForBlob when the blob hasn't appeared before the timeout.
+var ErrWaitTimeout = errors.New("azurestorage: timed out waiting for blob")
+
+// WaitForBlob polls every pollInterval until blobName exists in containerURL, returning nil as soon as it does. It
+// gives up with ErrWaitTimeout once timeout has elapsed, or with the context's error when ctx is done first.
+// A missing container counts as a missing blob, so the container may be created by the same upstream process.
+// timeout and pollInterval must both be positive.
+func WaitForBlob(ctx context.Context, containerURL azblob.ContainerURL, blobName string, timeout, pollInterval time.Duration) error {
+	if timeout <= 0 {
+		return fmt.Errorf("azurestorage: wait timeout %v must be positive", timeout)
+	}
+	if pollInterval <= 0 {
+		return fmt.Errorf("azurestorage: poll interval %v must be positive", pollInterval) // Or it would poll in a tight loop
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	blobURL := containerURL.NewBlobURL(blobName)
+	timer := time.NewTimer(0) // The first check happens straight away
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-waitCtx.Done():
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("%w %s after %v", ErrWaitTimeout, blobName, timeout)
+		case <-timer.C:
+		}
+
+		_, err := blobURL.GetProperties(waitCtx, azblob.BlobAccessConditions{}, azblob.ClientProvidedKeyOptions{})
+		if err == nil {
+			return nil
+		}
+		if !isBlobNotFound(err) && waitCtx.Err() == nil {
+			return err
+		}
+
+		timer.Reset(pollInterval)
+	}
+}
+
+func isBlobNotFound(err error) bool {
+	var stgErr azblob.StorageError
+	if !errors.As(err, &stgErr) {
+		return false
+	}
+
+	switch stgErr.ServiceCode() {
+	case azblob.ServiceCodeBlobNotFound, azblob.ServiceCodeContainerNotFound:
+		return true
+	}
+	return false
+}
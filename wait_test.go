@@ -0,0 +1,38 @@
+package azurestorage
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+)
+
+func TestWaitForBlobValidatesDurations(t *testing.T) {
+	u, err := url.Parse("https://account.blob.core.windows.net/container")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sent := errors.New("request sent")
+	containerURL := azblob.NewContainerURL(*u, failingPipeline(sent))
+
+	tests := []struct {
+		name                  string
+		timeout, pollInterval time.Duration
+	}{
+		{"zero timeout", 0, time.Second},
+		{"negative timeout", -time.Second, time.Second},
+		{"zero poll interval", time.Minute, 0},
+		{"negative poll interval", time.Minute, -time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := WaitForBlob(context.Background(), containerURL, "blob.txt", tt.timeout, tt.pollInterval)
+			if err == nil || errors.Is(err, sent) || errors.Is(err, ErrWaitTimeout) {
+				t.Errorf("WaitForBlob() error = %v, want an argument error before any request", err)
+			}
+		})
+	}
+}
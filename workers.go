@@ -0,0 +1,28 @@
+package azurestorage
+
+import (
+	"sync"
+)
+
+// defaultConcurrency is the number of simultaneous requests used when a caller passes a concurrency of zero or less.
+const defaultConcurrency = 16
+
+// runConcurrently calls fn once for every index in [0, n), running at most concurrency calls at the same time.
+func runConcurrently(n int, concurrency int, fn func(i int)) {
+	if concurrency <= 0 {
+		concurrency = defaultConcurrency
+	}
+
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			fn(i)
+		}(i)
+	}
+	wg.Wait()
+}